@@ -16,6 +16,7 @@ import (
 	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
 	load "github.com/Gituser143/stunning-octo-enigma/pkg/load-generator"
 	"github.com/Gituser143/stunning-octo-enigma/pkg/metricscraper"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/prometheus"
 	"github.com/Gituser143/stunning-octo-enigma/pkg/trigger"
 
 	flag "github.com/spf13/pflag"
@@ -32,8 +33,24 @@ func main() {
 	shouldLogThroughput := flag.BoolP("logth", "t", false, "Log e2e throughput of application (use alongside l or s)")
 	shouldLogQueueLens := flag.BoolP("logq", "q", false, "Log queue lengths and create json with threshold queue lengths for each deployment of application (use alongside l)")
 	shouldLogReqRate := flag.BoolP("logreq", "p", false, "Log request rate from load tester (use alongside l or s)")
+	shouldLogScaling := flag.BoolP("logscale", "e", false, "Log reactive and feed-forward scaling actions of the trigger to file (use alongside s)")
+	planFormat := flag.StringP("logplan", "g", "", "Render the annotated graph to file after every scaling action, as dot, mermaid or json (use alongside s)")
 	flag.Parse()
 
+	if *planFormat != "" {
+		if err := kiali.ValidateFormat(*planFormat); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Subcommands working on the application graph
+	if args := flag.Args(); len(args) > 0 && args[0] == "graph" {
+		if err := graphCommand(context.Background(), *filePath, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Get config from config file
 	conf, err := config.GetConfig(*filePath)
 	if err != nil {
@@ -47,42 +64,194 @@ func main() {
 	// Init a context
 	ctx := context.Background()
 
-	// Init Kiali Client
-	kc := kiali.NewKialiClient(conf.KialiHost.Host, conf.KialiHost.Port, nil)
+	tc, err := newTriggerClient(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("initialised trigger client")
+
+	if *loadtest {
+		loadTest(ctx, tc, conf, *shouldLogQueueLens, *shouldLogReplicaCounts, *shouldLogThroughput, *shouldLogReqRate)
+	} else if *scaleAndLoad {
+		if *shouldLogScaling {
+			go printScalingEvents(ctx, tc)
+		}
+
+		if *planFormat != "" {
+			go printPlans(ctx, tc, *planFormat)
+		}
+
+		// Run load test
+		go loadTest(ctx, tc, conf, *shouldLogQueueLens, *shouldLogReplicaCounts, *shouldLogThroughput, *shouldLogReqRate)
+
+		// Run Trigger
+		err = tc.StartTrigger(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// newTriggerClient inits the kiali, metrics and k8s clients and a trigger
+// client around them
+func newTriggerClient(conf config.Config) (*trigger.Client, error) {
+	// Init Kiali Client and the source of the dependency graph
+	kc, source, err := newDependencySource(conf)
+	if err != nil {
+		return nil, err
+	}
 
 	// Init Metrics Client
 	mc, err := metricscraper.NewMetricClient()
 	if err != nil {
-		log.Fatal("failed to init metrics client: ", err)
+		return nil, fmt.Errorf("failed to init metrics client: %w", err)
 	}
 
 	// Init K8s Client
 	k8sc, err := k8s.NewK8sClient()
 	if err != nil {
-		log.Fatal("failed to init k8s client")
+		return nil, fmt.Errorf("failed to init k8s client: %w", err)
 	}
 
 	// Init Trigger Client
 	tc := trigger.Client{
 		KialiClient:  kc,
+		Source:       source,
 		MetricClient: mc,
 		K8sClient:    k8sc,
 	}
 	tc.SetThresholds(conf.Thresholds)
-	log.Println("initialised trigger client")
 
-	if *loadtest {
-		loadTest(ctx, &tc, conf, *shouldLogQueueLens, *shouldLogReplicaCounts, *shouldLogThroughput, *shouldLogReqRate)
-	} else if *scaleAndLoad {
-		// Run load test
-		go loadTest(ctx, &tc, conf, *shouldLogQueueLens, *shouldLogReplicaCounts, *shouldLogThroughput, *shouldLogReqRate)
+	return &tc, nil
+}
 
-		// Run Trigger
-		err = tc.StartTrigger(ctx)
+// newDependencySource returns a kiali client along with the configured source
+// of the dependency graph, which is kiali itself by default
+func newDependencySource(conf config.Config) (*kiali.Client, trigger.DependencySource, error) {
+	kc, err := kiali.NewKialiClient(conf.KialiHost.Scheme, conf.KialiHost.Host, conf.KialiHost.Port, nil, conf.KialiAuth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to init kiali client: %w", err)
+	}
+
+	var pc *prometheus.Client
+	if conf.PrometheusHost.Host != "" {
+		pc = prometheus.NewPrometheusClient(conf.PrometheusHost.Scheme, conf.PrometheusHost.Host, conf.PrometheusHost.Port, nil)
+	}
+
+	source, err := trigger.NewDependencySource(conf.Thresholds.Source, kc, pc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to init dependency source: %w", err)
+	}
+
+	return kc, source, nil
+}
+
+// graphCommand runs a graph subcommand:
+//
+//	graph save <file>              saves a snapshot of the live graph
+//	graph diff <old> <new>         compares two saved snapshots
+//	graph export <format> [file]   renders the annotated live graph as dot,
+//	                               mermaid or json, to stdout if no file is given
+//
+// Exported graphs carry the decisions a trigger logging its scaling actions
+// saved to decisionsFile, and none without it.
+func graphCommand(ctx context.Context, filePath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: enigma graph save <file> | diff <old> <new> | export <format> [file]")
+	}
+
+	switch args[0] {
+	case "export":
+		if len(args) != 2 && len(args) != 3 {
+			return errors.New("usage: enigma graph export <format> [file]")
+		}
+		if err := kiali.ValidateFormat(args[1]); err != nil {
+			return err
+		}
+
+		conf, err := config.GetConfig(filePath)
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+
+		tc, err := newTriggerClient(conf)
+		if err != nil {
+			return err
+		}
+
+		decisions, err := loadDecisions()
+		if err != nil {
+			return err
+		}
+		tc.SetDecisions(decisions)
+
+		w := os.Stdout
+		if len(args) == 3 {
+			f, err := os.Create(args[2])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		return tc.ExportGraph(ctx, w, args[1])
+
+	case "save":
+		if len(args) != 2 {
+			return errors.New("usage: enigma graph save <file>")
+		}
+
+		conf, err := config.GetConfig(filePath)
+		if err != nil {
+			return err
+		}
+
+		_, source, err := newDependencySource(conf)
+		if err != nil {
+			return err
+		}
+
+		graph, err := source.GetGraph(ctx, kiali.GraphQuery{
+			GraphType:    conf.Thresholds.GraphType,
+			Namespaces:   conf.Namespaces,
+			Duration:     time.Minute,
+			ResponseTime: kiali.ResponseTimeAvg,
+			Throughput:   kiali.ThroughputResponse,
+			DeadNode:     true,
+		})
+		if err != nil {
+			return err
 		}
+
+		return kiali.SaveSnapshot(args[1], kiali.Snapshot{Time: time.Now(), Graph: graph})
+
+	case "diff":
+		if len(args) != 3 {
+			return errors.New("usage: enigma graph diff <old> <new>")
+		}
+
+		oldSnapshot, err := kiali.LoadSnapshot(args[1])
+		if err != nil {
+			return err
+		}
+
+		newSnapshot, err := kiali.LoadSnapshot(args[2])
+		if err != nil {
+			return err
+		}
+
+		d := kiali.Diff(oldSnapshot.Graph, newSnapshot.Graph, kiali.DefaultDiffThresholds)
+		if d.Empty() {
+			fmt.Printf("no changes from %v to %v\n", oldSnapshot.Time, newSnapshot.Time)
+			return nil
+		}
+
+		fmt.Printf("changes from %v to %v:\n%s\n", oldSnapshot.Time, newSnapshot.Time, d)
+		return nil
 	}
+
+	return fmt.Errorf("unknown graph command %q", args[0])
 }
 
 func printThrpughput(ctx context.Context, tc *trigger.Client) {
@@ -141,6 +310,115 @@ func printReqRate(ctx context.Context, tc *trigger.Client) {
 	}
 }
 
+// decisionsFile is where the decisions of the last scaling cycles are saved
+// for graphs exported by another process
+const decisionsFile = "decisions.json"
+
+// saveDecisions saves what the last scaling cycles decided for every node
+func saveDecisions(decisions map[string]string) error {
+	bs, err := json.MarshalIndent(decisions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(decisionsFile, bs, 0644)
+}
+
+// loadDecisions loads the decisions saved by saveDecisions, or none if no
+// trigger saved any
+func loadDecisions() (map[string]string, error) {
+	decisions := make(map[string]string)
+
+	bs, err := ioutil.ReadFile(decisionsFile)
+	if os.IsNotExist(err) {
+		return decisions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bs, &decisions); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", decisionsFile, err)
+	}
+
+	return decisions, nil
+}
+
+func printScalingEvents(ctx context.Context, tc *trigger.Client) {
+	f, err := os.OpenFile("scaling_events.csv", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		// log.Println(err)
+	}
+
+	defer f.Close()
+
+	logged := 0
+	logTicker := time.NewTicker(5 * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-logTicker.C:
+			events := tc.ScalingEvents()
+			for _, event := range events[logged:] {
+				// Lead is how long ahead of its load step a feed-forward action was taken
+				lead := ""
+				if !event.StepStart.IsZero() {
+					lead = fmt.Sprintf("%g", event.StepStart.Sub(event.Time).Seconds())
+				}
+
+				es := fmt.Sprintf("%s,%s,%d,%d,%s,%v\n", event.Origin, event.Service, event.OldReplicas, event.NewReplicas, lead, event.Time)
+				if _, err := f.WriteString(es); err != nil {
+					// log.Println(err)
+				}
+			}
+
+			// Keep the decisions next to the events for graph export
+			if len(events) > logged {
+				if err := saveDecisions(tc.Decisions()); err != nil {
+					log.Println("error saving decisions:", err)
+				}
+			}
+			logged = len(events)
+		}
+	}
+}
+
+// printPlans renders the last plan which scaled any node on the graph it was
+// decided on, annotated with what was decided for its nodes, whenever a new
+// one is applied
+func printPlans(ctx context.Context, tc *trigger.Client, format string) {
+	fileName := fmt.Sprintf("plan.%s", format)
+
+	rendered := 0
+	logTicker := time.NewTicker(5 * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-logTicker.C:
+			plan, plans := tc.LastPlan()
+			if plans == rendered {
+				continue
+			}
+
+			f, err := os.Create(fileName)
+			if err != nil {
+				log.Println("error creating plan file:", err)
+				continue
+			}
+
+			if err := plan.Graph.Export(f, format, plan.Annotations); err != nil {
+				log.Println("error rendering plan:", err)
+			}
+			f.Close()
+			rendered = plans
+		}
+	}
+}
+
 func printReplicaCount(ctx context.Context, tc *trigger.Client, namespace string) {
 	f, err := os.OpenFile("replica_counts.csv", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -188,17 +466,33 @@ func loadTest(
 	sc := load.NewStressClient(conf.AppHost.Scheme, conf.AppHost.Host, conf.AppHost.Port, nil)
 	sc.SetTargetFunction(sc.GetTeaStoreTargets)
 
-	parameters := map[string]string{
-		"responseTime": "avg",
-		"throughput":   "response",
-		"duration":     "1m",
+	// Hand the load schedule to the trigger for feed-forward scaling
+	sc.SetScheduleFunction(func(steps []load.Step) {
+		schedule := make([]trigger.LoadStep, len(steps))
+		for i, step := range steps {
+			schedule[i] = trigger.LoadStep{
+				Index:    step.Index,
+				Start:    step.Start,
+				Rate:     float64(step.Rate),
+				Duration: step.Duration,
+			}
+		}
+		tc.SetLoadSchedule(schedule)
+	})
+
+	query := kiali.GraphQuery{
+		Namespaces:   conf.Namespaces,
+		Duration:     time.Minute,
+		ResponseTime: kiali.ResponseTimeAvg,
+		Throughput:   kiali.ThroughputResponse,
+		DeadNode:     true,
 	}
 
 	exitChan := make(chan int)
 
 	if shouldLogQueueLens {
 		// Write queue lengths to file
-		go logQueuelengths(ctx, tc, conf.Namespaces, parameters, exitChan, &wg)
+		go logQueuelengths(ctx, tc, query, exitChan, &wg)
 	}
 
 	if shouldLogReplicaCounts {
@@ -225,8 +519,7 @@ func loadTest(
 func logQueuelengths(
 	ctx context.Context,
 	tc *trigger.Client,
-	namespaces []string,
-	parameters map[string]string,
+	query kiali.GraphQuery,
 	exitChan chan int,
 	wg *sync.WaitGroup,
 ) {
@@ -261,7 +554,7 @@ func logQueuelengths(
 		select {
 		case <-t.C:
 			// Get workload graph for a namespace
-			graph, err := tc.KialiClient.GetWorkloadGraph(egCtx, namespaces, parameters)
+			graph, err := tc.CachedGraph(egCtx, query)
 			if err != nil {
 				// // log.Println(err)
 			} else {
@@ -312,8 +605,15 @@ func exampleUsageMetricClient() error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case metric := <-podMetrics:
-			fmt.Println(metric.Containers[0].Usage.Cpu())
+		case result, ok := <-podMetrics:
+			if !ok {
+				return ctx.Err()
+			}
+			if result.Err != nil {
+				log.Println("error getting pod metrics:", result.Err)
+				continue
+			}
+			fmt.Println(result.Metrics.Containers[0].Usage.Cpu())
 		}
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
 	"github.com/Gituser143/stunning-octo-enigma/pkg/trigger"
 )
 
@@ -31,13 +32,17 @@ type LoadParameters struct {
 
 // Config holds configuration details of Kiali, Application endpoints along
 // with relevant load parameters, namespaces to use and per deployment
-// resource thresholds.
+// resource thresholds. KialiAuth holds the TLS and credential details used to
+// talk to Kiali. PrometheusHost is only needed when the dependency graph is
+// taken from prometheus or declared statically.
 type Config struct {
-	KialiHost  Host               `json:"kialiHost"`
-	AppHost    Host               `json:"appHost"`
-	Thresholds trigger.Thresholds `json:"thresholds"`
-	LoadConfig LoadParameters     `json:"loadParameters"`
-	Namespaces []string           `json:"namespaces"`
+	KialiHost      Host               `json:"kialiHost"`
+	KialiAuth      kiali.Auth         `json:"kialiAuth"`
+	PrometheusHost Host               `json:"prometheusHost"`
+	AppHost        Host               `json:"appHost"`
+	Thresholds     trigger.Thresholds `json:"thresholds"`
+	LoadConfig     LoadParameters     `json:"loadParameters"`
+	Namespaces     []string           `json:"namespaces"`
 }
 
 // ErrInavlidConfigPath signifies the error when a path to a config file is not
@@ -0,0 +1,123 @@
+package forecast
+
+// HoltWinters is an additive Holt-Winters (triple exponential smoothing)
+// model over a series of evenly spaced samples. Alpha, Beta and Gamma smooth
+// the level, trend and seasonal components respectively. With a season length
+// of 1 or less, the model has no seasonal component and reduces to Holt's
+// linear trend model.
+type HoltWinters struct {
+	alpha        float64
+	beta         float64
+	gamma        float64
+	seasonLength int
+
+	initialised bool
+	buffer      []float64
+	level       float64
+	trend       float64
+	seasonals   []float64
+	n           int
+}
+
+// NewHoltWinters is a constructor for HoltWinters
+func NewHoltWinters(alpha, beta, gamma float64, seasonLength int) *HoltWinters {
+	if seasonLength < 1 {
+		seasonLength = 1
+	}
+
+	return &HoltWinters{
+		alpha:        alpha,
+		beta:         beta,
+		gamma:        gamma,
+		seasonLength: seasonLength,
+		seasonals:    make([]float64, seasonLength),
+	}
+}
+
+// Add feeds the next sample of the series to the model. The model buffers the
+// first two seasons of samples (or two samples without seasonality) to
+// initialise its components before it starts smoothing.
+func (hw *HoltWinters) Add(x float64) {
+	if hw.initialised {
+		hw.update(x)
+		return
+	}
+
+	hw.buffer = append(hw.buffer, x)
+	if len(hw.buffer) < hw.warmup() {
+		return
+	}
+
+	hw.initialise()
+	for _, sample := range hw.buffer {
+		hw.update(sample)
+	}
+	hw.buffer = nil
+}
+
+// Ready reports whether the model has seen enough samples to forecast.
+func (hw *HoltWinters) Ready() bool {
+	return hw.initialised
+}
+
+// Forecast returns the value the model expects h samples after the last one
+// it was fed. It returns false if the model is not ready yet.
+func (hw *HoltWinters) Forecast(h int) (float64, bool) {
+	if !hw.initialised {
+		return 0, false
+	}
+
+	if h < 1 {
+		h = 1
+	}
+
+	seasonal := hw.seasonals[(hw.n+h-1)%hw.seasonLength]
+	return hw.level + float64(h)*hw.trend + seasonal, true
+}
+
+func (hw *HoltWinters) warmup() int {
+	return 2 * hw.seasonLength
+}
+
+// initialise sets the level and trend from the means of the first two seasons
+// and the seasonal components from the deviations of the samples from them.
+func (hw *HoltWinters) initialise() {
+	L := hw.seasonLength
+	first, second := mean(hw.buffer[:L]), mean(hw.buffer[L:2*L])
+
+	hw.level = first
+	hw.trend = (second - first) / float64(L)
+	if L > 1 {
+		for i := 0; i < L; i++ {
+			hw.seasonals[i] = ((hw.buffer[i] - first) + (hw.buffer[i+L] - second)) / 2
+		}
+	}
+
+	hw.initialised = true
+}
+
+func (hw *HoltWinters) update(x float64) {
+	i := hw.n % hw.seasonLength
+	lastLevel := hw.level
+
+	hw.level = hw.alpha*(x-hw.seasonals[i]) + (1-hw.alpha)*(hw.level+hw.trend)
+	hw.trend = hw.beta*(hw.level-lastLevel) + (1-hw.beta)*hw.trend
+	if hw.seasonLength > 1 {
+		hw.seasonals[i] = hw.gamma*(x-hw.level) + (1-hw.gamma)*hw.seasonals[i]
+	}
+
+	hw.n++
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+
+	return sum / float64(len(xs))
+}
@@ -0,0 +1,118 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+// seasonal holds the deviations of every sample of a season from the level,
+// summing to 0
+var seasonal = []float64{3, -1, -3, 1}
+
+// seasonalSeries returns n samples of a series with the given level and trend
+// per sample, repeating seasonal
+func seasonalSeries(n int, level, trend float64) []float64 {
+	xs := make([]float64, n)
+	for t := range xs {
+		xs[t] = level + trend*float64(t) + seasonal[t%len(seasonal)]
+	}
+
+	return xs
+}
+
+func TestHoltWintersWarmup(t *testing.T) {
+	cases := []struct {
+		seasonLength int
+		warmup       int
+	}{
+		{0, 2},
+		{1, 2},
+		{4, 8},
+		{12, 24},
+	}
+
+	for _, c := range cases {
+		hw := NewHoltWinters(0.5, 0.5, 0.5, c.seasonLength)
+		for i := 0; i < c.warmup-1; i++ {
+			hw.Add(float64(i))
+		}
+
+		if hw.Ready() {
+			t.Errorf("season length %d: expected not ready after %d samples", c.seasonLength, c.warmup-1)
+		}
+		if _, ok := hw.Forecast(1); ok {
+			t.Errorf("season length %d: expected no forecast before warmup", c.seasonLength)
+		}
+
+		hw.Add(float64(c.warmup - 1))
+		if !hw.Ready() {
+			t.Errorf("season length %d: expected ready after %d samples", c.seasonLength, c.warmup)
+		}
+		if _, ok := hw.Forecast(1); !ok {
+			t.Errorf("season length %d: expected a forecast after warmup", c.seasonLength)
+		}
+	}
+}
+
+func TestHoltWintersSeasonal(t *testing.T) {
+	// Without a trend, the components found on warmup already fit the series
+	// and smoothing leaves them as they are
+	hw := NewHoltWinters(0.5, 0.5, 0.5, len(seasonal))
+	xs := seasonalSeries(11, 10, 0)
+	for _, x := range xs {
+		hw.Add(x)
+	}
+
+	for h := 1; h <= 8; h++ {
+		want := 10 + seasonal[(len(xs)+h-1)%len(seasonal)]
+		got, _ := hw.Forecast(h)
+		if math.Abs(got-want) > epsilon {
+			t.Errorf("h=%d: expected %.2f, got %.2f", h, want, got)
+		}
+	}
+}
+
+func TestHoltWintersSeasonalTrend(t *testing.T) {
+	// With a trend, the components converge onto the series
+	hw := NewHoltWinters(0.5, 0.3, 0.5, len(seasonal))
+	xs := seasonalSeries(104, 10, 2)
+	for _, x := range xs {
+		hw.Add(x)
+	}
+
+	for h := 1; h <= 4; h++ {
+		want := 10 + 2*float64(len(xs)-1+h) + seasonal[(len(xs)+h-1)%len(seasonal)]
+		got, _ := hw.Forecast(h)
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("h=%d: expected %.2f, got %.2f", h, want, got)
+		}
+	}
+}
+
+func TestHoltWintersLinear(t *testing.T) {
+	// Without smoothing, the level is the last sample and the trend the last
+	// difference
+	hw := NewHoltWinters(1, 1, 0, 0)
+	for _, x := range []float64{1, 3, 5, 7} {
+		hw.Add(x)
+	}
+
+	cases := []struct {
+		h    int
+		want float64
+	}{
+		{0, 9},
+		{1, 9},
+		{2, 11},
+		{5, 17},
+	}
+
+	for _, c := range cases {
+		got, _ := hw.Forecast(c.h)
+		if math.Abs(got-c.want) > epsilon {
+			t.Errorf("h=%d: expected %.2f, got %.2f", c.h, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package forecast
+
+import "math"
+
+// Accuracy summarises how well past forecasts of a series matched the samples
+// that later arrived. MAPE leaves out samples of value 0.
+type Accuracy struct {
+	Count int
+	MAE   float64
+	MAPE  float64
+}
+
+// Series keeps a rolling window of the latest samples of a series and feeds
+// them to a HoltWinters model. Every forecast made is remembered until the
+// sample it was made for arrives, so that the accuracy of the model can be
+// judged over the same window.
+type Series struct {
+	model   *HoltWinters
+	window  int
+	n       int
+	samples []float64
+	pending map[int]float64
+	errors  []float64
+	pctErrs []float64
+}
+
+// NewSeries is a constructor for Series
+func NewSeries(model *HoltWinters, window int) *Series {
+	if window < 1 {
+		window = 1
+	}
+
+	return &Series{
+		model:   model,
+		window:  window,
+		pending: make(map[int]float64),
+	}
+}
+
+// Add appends the next sample to the series, scoring any forecast that was
+// made for it.
+func (s *Series) Add(x float64) {
+	if forecast, ok := s.pending[s.n]; ok {
+		delete(s.pending, s.n)
+
+		s.errors = appendWindow(s.errors, math.Abs(x-forecast), s.window)
+		if x != 0 {
+			s.pctErrs = appendWindow(s.pctErrs, math.Abs((x-forecast)/x), s.window)
+		}
+	}
+
+	s.samples = appendWindow(s.samples, x, s.window)
+	s.model.Add(x)
+	s.n++
+}
+
+// Forecast returns the value expected h samples after the latest one and
+// remembers it for scoring. It returns false if the model is not ready yet.
+func (s *Series) Forecast(h int) (float64, bool) {
+	if h < 1 {
+		h = 1
+	}
+
+	forecast, ok := s.model.Forecast(h)
+	if !ok {
+		return 0, false
+	}
+
+	s.pending[s.n-1+h] = forecast
+	return forecast, true
+}
+
+// Last returns the latest sample of the series.
+func (s *Series) Last() (float64, bool) {
+	if len(s.samples) == 0 {
+		return 0, false
+	}
+
+	return s.samples[len(s.samples)-1], true
+}
+
+// Samples returns the samples currently in the window, oldest first.
+func (s *Series) Samples() []float64 {
+	samples := make([]float64, len(s.samples))
+	copy(samples, s.samples)
+	return samples
+}
+
+// Accuracy returns the accuracy of the forecasts scored within the window.
+func (s *Series) Accuracy() Accuracy {
+	a := Accuracy{Count: len(s.errors)}
+	a.MAE = mean(s.errors)
+	a.MAPE = mean(s.pctErrs) * 100
+	return a
+}
+
+func appendWindow(xs []float64, x float64, window int) []float64 {
+	xs = append(xs, x)
+	if len(xs) > window {
+		xs = xs[len(xs)-window:]
+	}
+
+	return xs
+}
@@ -0,0 +1,87 @@
+package forecast
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSeriesAccuracy(t *testing.T) {
+	// Without smoothing, every forecast carries on from the last sample by
+	// the last difference
+	s := NewSeries(NewHoltWinters(1, 1, 0, 0), 3)
+
+	if _, ok := s.Forecast(1); ok {
+		t.Fatal("expected no forecast before warmup")
+	}
+
+	steps := []struct {
+		sample  float64
+		h       int
+		predict float64
+	}{
+		{1, 0, 0},
+		{3, 1, 5},
+		{4, 1, 5},
+		{0, 2, -8},
+		{-4, 0, 0},
+		{-4, 0, 0},
+	}
+
+	for i, step := range steps {
+		s.Add(step.sample)
+		if step.h == 0 {
+			continue
+		}
+
+		got, ok := s.Forecast(step.h)
+		if !ok || math.Abs(got-step.predict) > epsilon {
+			t.Fatalf("sample %d: expected forecast %.2f, got %.2f", i, step.predict, got)
+		}
+	}
+
+	// Errors of 1, 5 and 4, the sample of 0 being left out of MAPE
+	a := s.Accuracy()
+	if a.Count != 3 {
+		t.Errorf("expected 3 scored forecasts, got %d", a.Count)
+	}
+	if want := 10.0 / 3; math.Abs(a.MAE-want) > epsilon {
+		t.Errorf("expected MAE %.3f, got %.3f", want, a.MAE)
+	}
+	if want := 62.5; math.Abs(a.MAPE-want) > epsilon {
+		t.Errorf("expected MAPE %.3f, got %.3f", want, a.MAPE)
+	}
+
+	if want := []float64{0, -4, -4}; !reflect.DeepEqual(s.Samples(), want) {
+		t.Errorf("expected samples %v, got %v", want, s.Samples())
+	}
+	if last, ok := s.Last(); !ok || last != -4 {
+		t.Errorf("expected last sample -4, got %.2f", last)
+	}
+}
+
+func TestSeriesWindow(t *testing.T) {
+	s := NewSeries(NewHoltWinters(0.5, 0.3, 0.5, len(seasonal)), 4)
+	if _, ok := s.Last(); ok {
+		t.Fatal("expected no last sample in an empty series")
+	}
+
+	// Forecast a step ahead of every sample of a seasonal series, only the
+	// last 4 of which are scored
+	xs := seasonalSeries(40, 10, 0)
+	for _, x := range xs {
+		s.Add(x)
+		s.Forecast(1)
+	}
+
+	a := s.Accuracy()
+	if a.Count != 4 {
+		t.Errorf("expected 4 scored forecasts, got %d", a.Count)
+	}
+	if a.MAE > epsilon || a.MAPE > epsilon {
+		t.Errorf("expected exact forecasts, got MAE %.3f and MAPE %.3f", a.MAE, a.MAPE)
+	}
+	if want := xs[len(xs)-4:]; !reflect.DeepEqual(s.Samples(), want) {
+		t.Errorf("expected samples %v, got %v", want, s.Samples())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -155,3 +156,34 @@ func (c *Client) GetCurrentReplicaCount(ctx context.Context, namespace, name str
 
 	return s.Status.Replicas, err
 }
+
+// GetDeploymentsForSelector gets names of deployments whose pod template
+// carries every label of the given selector.
+func (c *Client) GetDeploymentsForSelector(ctx context.Context, namespace string, selector map[string]string) ([]string, error) {
+	deployments, err := c.GetDeployments(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	s := labels.SelectorFromSet(selector)
+	names := []string{}
+	for _, d := range deployments {
+		if s.Matches(labels.Set(d.Spec.Template.Labels)) {
+			names = append(names, d.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// GetServiceSelector fetches the pod selector of a service in a namespace
+func (c *Client) GetServiceSelector(ctx context.Context, namespace, name string) (map[string]string, error) {
+	svc, err := c.client.CoreV1().
+		Services(namespace).
+		Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.Spec.Selector, nil
+}
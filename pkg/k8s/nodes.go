@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetNodes lists out all nodes of the cluster.
+func (c *Client) GetNodes(ctx context.Context) ([]apiv1.Node, error) {
+	nodeList, err := c.client.CoreV1().
+		Nodes().
+		List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeList.Items, nil
+}
+
+// GetFreeResources returns what is left of the allocatable resources of every
+// node that is ready and accepts new pods, keyed by node name, once the
+// requests of the pods bound to it that have not terminated are taken away.
+// The number of pods a node still has room for is reported under
+// apiv1.ResourcePods.
+func (c *Client) GetFreeResources(ctx context.Context) (map[string]apiv1.ResourceList, error) {
+	nodes, err := c.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := c.GetPods(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+
+	free := make(map[string]apiv1.ResourceList)
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !isNodeReady(node) {
+			continue
+		}
+		free[node.Name] = node.Status.Allocatable.DeepCopy()
+	}
+
+	for _, pod := range pods {
+		nodeFree, ok := free[pod.Spec.NodeName]
+		if !ok || isPodTerminated(pod) {
+			continue
+		}
+
+		requests := PodRequests(pod.Spec)
+		requests[apiv1.ResourcePods] = *resource.NewQuantity(1, resource.DecimalSI)
+		for name, quantity := range requests {
+			if current, ok := nodeFree[name]; ok {
+				current.Sub(quantity)
+				nodeFree[name] = current
+			}
+		}
+	}
+
+	return free, nil
+}
+
+// GetPendingPods returns the pods of a namespace that are yet to be scheduled
+// onto a node. Use metav1.NamespaceAll to look across all namespaces.
+func (c *Client) GetPendingPods(ctx context.Context, namespace string) ([]apiv1.Pod, error) {
+	pods, err := c.GetPods(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []apiv1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase == apiv1.PodPending && pod.Spec.NodeName == "" {
+			pending = append(pending, pod)
+		}
+	}
+
+	return pending, nil
+}
+
+// PodRequests returns the effective resource requests of a pod. This is the
+// sum of requests of all its containers or the largest request of any init
+// container, whichever is higher.
+func PodRequests(spec apiv1.PodSpec) apiv1.ResourceList {
+	return podResources(spec, func(r apiv1.ResourceRequirements) apiv1.ResourceList {
+		return r.Requests
+	})
+}
+
+// PodLimits returns the effective resource limits of a pod, worked out the
+// same way as PodRequests.
+func PodLimits(spec apiv1.PodSpec) apiv1.ResourceList {
+	return podResources(spec, func(r apiv1.ResourceRequirements) apiv1.ResourceList {
+		return r.Limits
+	})
+}
+
+func podResources(spec apiv1.PodSpec, get func(apiv1.ResourceRequirements) apiv1.ResourceList) apiv1.ResourceList {
+	res := apiv1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResourceList(res, get(container.Resources))
+	}
+
+	for _, container := range spec.InitContainers {
+		for name, quantity := range get(container.Resources) {
+			if current, ok := res[name]; !ok || quantity.Cmp(current) > 0 {
+				res[name] = quantity.DeepCopy()
+			}
+		}
+	}
+
+	return res
+}
+
+// addResourceList adds every quantity in src to the matching quantity in dst.
+func addResourceList(dst, src apiv1.ResourceList) {
+	for name, quantity := range src {
+		if current, ok := dst[name]; ok {
+			current.Add(quantity)
+			dst[name] = current
+		} else {
+			dst[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+func isNodeReady(node apiv1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == apiv1.NodeReady {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func isPodTerminated(pod apiv1.Pod) bool {
+	return pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed
+}
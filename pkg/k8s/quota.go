@@ -0,0 +1,129 @@
+package k8s
+
+import (
+	"context"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetResourceQuotas lists out all ResourceQuotas of a namespace.
+func (c *Client) GetResourceQuotas(ctx context.Context, namespace string) ([]apiv1.ResourceQuota, error) {
+	quotaList, err := c.client.CoreV1().
+		ResourceQuotas(namespace).
+		List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return quotaList.Items, nil
+}
+
+// GetLimitRanges lists out all LimitRanges of a namespace.
+func (c *Client) GetLimitRanges(ctx context.Context, namespace string) ([]apiv1.LimitRange, error) {
+	limitRangeList, err := c.client.CoreV1().
+		LimitRanges(namespace).
+		List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return limitRangeList.Items, nil
+}
+
+// GetRemainingQuota returns how much of each resource can still be used in a
+// namespace before one of its ResourceQuotas is exhausted. When several
+// quotas constrain the same resource, the smallest remainder wins. Resources
+// that no quota constrains are left out. Quota scopes are not looked at, every
+// quota is taken to apply to every pod.
+func (c *Client) GetRemainingQuota(ctx context.Context, namespace string) (apiv1.ResourceList, error) {
+	quotas, err := c.GetResourceQuotas(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := apiv1.ResourceList{}
+	for _, quota := range quotas {
+		for name, hard := range quota.Status.Hard {
+			left := hard.DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				left.Sub(used)
+			}
+
+			if current, ok := remaining[name]; !ok || left.Cmp(current) < 0 {
+				remaining[name] = left
+			}
+		}
+	}
+
+	return remaining, nil
+}
+
+// GetPodTemplateResources returns the resource requests and limits a single
+// pod of a deployment ends up with once the LimitRanges of its namespace have
+// been applied to its pod template.
+func (c *Client) GetPodTemplateResources(ctx context.Context, namespace, name string) (apiv1.ResourceList, apiv1.ResourceList, error) {
+	d, err := c.client.AppsV1().
+		Deployments(namespace).
+		Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limitRanges, err := c.GetLimitRanges(ctx, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spec := d.Spec.Template.Spec.DeepCopy()
+	ApplyLimitRangeDefaults(spec, limitRanges)
+
+	return PodRequests(*spec), PodLimits(*spec), nil
+}
+
+// ApplyLimitRangeDefaults fills in the container requests and limits that the
+// API server would default for a pod. Missing requests are taken from the
+// container's own limits and failing that from the LimitRange default request,
+// missing limits from the LimitRange default.
+func ApplyLimitRangeDefaults(spec *apiv1.PodSpec, limitRanges []apiv1.LimitRange) {
+	defaultRequests := apiv1.ResourceList{}
+	defaultLimits := apiv1.ResourceList{}
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != apiv1.LimitTypeContainer {
+				continue
+			}
+			mergeMissing(defaultLimits, item.Default)
+			mergeMissing(defaultRequests, item.DefaultRequest)
+			mergeMissing(defaultRequests, item.Default)
+		}
+	}
+
+	applyDefaults := func(containers []apiv1.Container) {
+		for i := range containers {
+			resources := &containers[i].Resources
+			if resources.Limits == nil {
+				resources.Limits = apiv1.ResourceList{}
+			}
+			if resources.Requests == nil {
+				resources.Requests = apiv1.ResourceList{}
+			}
+
+			mergeMissing(resources.Requests, resources.Limits)
+			mergeMissing(resources.Limits, defaultLimits)
+			mergeMissing(resources.Requests, defaultRequests)
+		}
+	}
+
+	applyDefaults(spec.Containers)
+	applyDefaults(spec.InitContainers)
+}
+
+// mergeMissing copies over quantities from src that dst does not hold yet.
+func mergeMissing(dst, src apiv1.ResourceList) {
+	for name, quantity := range src {
+		if _, ok := dst[name]; !ok {
+			dst[name] = quantity.DeepCopy()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package kiali
+
+import (
+	"sort"
+
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// IsUnknown reports whether a node stands for traffic from outside the mesh
+func (i *Item) IsUnknown() bool {
+	return i.Type == NodeTypeUnknown
+}
+
+// EntryNodes returns the IDs of the nodes traffic enters the graph through,
+// i.e. the unknown nodes and the root nodes, sorted
+func (g Graph) EntryNodes() []string {
+	ids := []string{}
+	for id, item := range g {
+		if item.IsUnknown() || item.IsRoot {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// Outbound returns the edges leaving a node
+func (g Graph) Outbound(id string) []*graph.EdgeData {
+	item, ok := g[id]
+	if !ok {
+		return nil
+	}
+
+	return item.Edges
+}
+
+// Inbound returns the edges coming into a node, ordered by source ID
+func (g Graph) Inbound(id string) []*graph.EdgeData {
+	edges := []*graph.EdgeData{}
+	for _, item := range g {
+		for _, edge := range item.Edges {
+			if edge.Target == id {
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Source != edges[j].Source {
+			return edges[i].Source < edges[j].Source
+		}
+		return edges[i].ID < edges[j].ID
+	})
+	return edges
+}
+
+// InboundRate returns the rate of requests coming into a node, summed over
+// its inbound edges, in requests per second
+func (g Graph) InboundRate(id string) float64 {
+	rate := 0.0
+	for _, edge := range g.Inbound(id) {
+		rate += parseMetric(edge.Traffic.Rates[edge.Traffic.Protocol])
+	}
+
+	return rate
+}
+
+// FanOut returns how many calls to target every request into source leads
+// to, if the source of the graph knows it
+func (g Graph) FanOut(source, target string) (float64, bool) {
+	item, ok := g[source]
+	if !ok {
+		return 0, false
+	}
+
+	fanOut, ok := item.FanOuts[target]
+	return fanOut, ok
+}
+
+// Successors returns the IDs of the nodes a node sends traffic to, sorted
+func (g Graph) Successors(id string) []string {
+	seen := make(map[string]bool)
+	for _, edge := range g.Outbound(id) {
+		seen[edge.Target] = true
+	}
+
+	return sortedKeys(seen)
+}
+
+// Predecessors returns the IDs of the nodes sending traffic to a node, sorted
+func (g Graph) Predecessors(id string) []string {
+	seen := make(map[string]bool)
+	for _, edge := range g.Inbound(id) {
+		seen[edge.Source] = true
+	}
+
+	return sortedKeys(seen)
+}
+
+// Neighbours returns the IDs of the nodes sharing an edge with a node in
+// either direction, sorted
+func (g Graph) Neighbours(id string) []string {
+	seen := make(map[string]bool)
+	for _, edge := range g.Outbound(id) {
+		seen[edge.Target] = true
+	}
+	for _, edge := range g.Inbound(id) {
+		seen[edge.Source] = true
+	}
+	delete(seen, id)
+
+	return sortedKeys(seen)
+}
+
+// Lookup returns the ID of the node going by a name, see Item.Name
+func (g Graph) Lookup(name string) (string, bool) {
+	for id, item := range g {
+		if item.Name() == name {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
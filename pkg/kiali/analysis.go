@@ -0,0 +1,230 @@
+package kiali
+
+import (
+	"sort"
+	"strconv"
+)
+
+// sortedIDs returns the IDs of all nodes of a graph, sorted
+func (g Graph) sortedIDs() []string {
+	ids := make([]string, 0, len(g))
+	for id := range g {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// Reverse returns the reverse adjacency of a graph, i.e. the IDs of the nodes
+// sending traffic to every node, sorted
+func (g Graph) Reverse() map[string][]string {
+	reverse := make(map[string][]string)
+	for _, id := range g.sortedIDs() {
+		reverse[id] = []string{}
+	}
+
+	for _, id := range g.sortedIDs() {
+		for _, target := range g.Successors(id) {
+			reverse[target] = append(reverse[target], id)
+		}
+	}
+
+	return reverse
+}
+
+// StronglyConnectedComponents returns the strongly connected components of a
+// graph. Components of more than one node, or of a node calling itself, are
+// call cycles. Every component is sorted, and components come in reverse
+// topological order, callees before their callers.
+func (g Graph) StronglyConnectedComponents() [][]string {
+	// Tarjan's algorithm
+	index := 0
+	indices := make(map[string]int)
+	lowLinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := []string{}
+	components := [][]string{}
+
+	var connect func(id string)
+	connect = func(id string) {
+		indices[id] = index
+		lowLinks[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, target := range g.Successors(id) {
+			if _, ok := indices[target]; !ok {
+				connect(target)
+				if lowLinks[target] < lowLinks[id] {
+					lowLinks[id] = lowLinks[target]
+				}
+			} else if onStack[target] && indices[target] < lowLinks[id] {
+				lowLinks[id] = indices[target]
+			}
+		}
+
+		if lowLinks[id] != indices[id] {
+			return
+		}
+
+		component := []string{}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+
+		sort.Strings(component)
+		components = append(components, component)
+	}
+
+	for _, id := range g.sortedIDs() {
+		if _, ok := indices[id]; !ok {
+			connect(id)
+		}
+	}
+
+	return components
+}
+
+// Cycles returns the call cycles of a graph, as the strongly connected
+// components which hold one
+func (g Graph) Cycles() [][]string {
+	cycles := [][]string{}
+	for _, component := range g.StronglyConnectedComponents() {
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+			continue
+		}
+
+		for _, target := range g.Successors(component[0]) {
+			if target == component[0] {
+				cycles = append(cycles, component)
+				break
+			}
+		}
+	}
+
+	return cycles
+}
+
+// TopologicalOrder returns the IDs of all nodes of a graph with every caller
+// before its callees. Nodes of a call cycle cannot be ordered among each other
+// and are kept together, sorted by ID.
+func (g Graph) TopologicalOrder() []string {
+	components := g.StronglyConnectedComponents()
+
+	order := make([]string, 0, len(g))
+	for i := len(components) - 1; i >= 0; i-- {
+		order = append(order, components[i]...)
+	}
+
+	return order
+}
+
+// Reachable returns the IDs of the nodes reachable from the given nodes,
+// those included
+func (g Graph) Reachable(from []string) map[string]bool {
+	reached := make(map[string]bool)
+	queue := []string{}
+	for _, id := range from {
+		if _, ok := g[id]; ok && !reached[id] {
+			reached[id] = true
+			queue = append(queue, id)
+		}
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, target := range g.Successors(id) {
+			if !reached[target] {
+				reached[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	return reached
+}
+
+// ReachableFromEntries returns the IDs of the nodes traffic entering the graph
+// through the entry nodes picked by selector can reach, see SelectEntries
+func (g Graph) ReachableFromEntries(selector EntrySelector) map[string]bool {
+	return g.Reachable(g.SelectEntries(selector))
+}
+
+// CriticalPath returns the path from one of the given entry nodes with the
+// highest cumulative response time, summed over its edges in milliseconds,
+// along with that time. Edges within a call cycle are not followed.
+func (g Graph) CriticalPath(entries []string) ([]string, float64) {
+	component := make(map[string]int)
+	for i, c := range g.StronglyConnectedComponents() {
+		for _, id := range c {
+			component[id] = i
+		}
+	}
+
+	reached := g.Reachable(entries)
+
+	// Longest time to every node, and the node it is reached from
+	times := make(map[string]float64)
+	previous := make(map[string]string)
+	for _, id := range entries {
+		if _, ok := g[id]; ok {
+			times[id] = 0
+		}
+	}
+
+	for _, id := range g.TopologicalOrder() {
+		t, ok := times[id]
+		if !ok || !reached[id] {
+			continue
+		}
+
+		for _, edge := range g.Outbound(id) {
+			if component[edge.Target] == component[id] {
+				continue
+			}
+
+			responseTime, err := strconv.ParseFloat(edge.ResponseTime, 64)
+			if err != nil {
+				responseTime = 0
+			}
+
+			if current, ok := times[edge.Target]; !ok || t+responseTime > current {
+				times[edge.Target] = t + responseTime
+				previous[edge.Target] = id
+			}
+		}
+	}
+
+	last, longest := "", -1.0
+	for _, id := range g.sortedIDs() {
+		if t, ok := times[id]; ok && t > longest {
+			last, longest = id, t
+		}
+	}
+
+	if last == "" {
+		return nil, 0
+	}
+
+	path := []string{last}
+	for {
+		id, ok := previous[path[0]]
+		if !ok {
+			break
+		}
+		path = append([]string{id}, path...)
+	}
+
+	return path, longest
+}
@@ -0,0 +1,181 @@
+package kiali
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// testConfig returns the config of a workload graph with the given edges,
+// each as "<source> <target> <response time>". The "unknown" node stands for
+// callers outside, and nodes without inbound edges are roots.
+func testConfig(edges ...string) *graph.Config {
+	conf := &graph.Config{GraphType: GraphTypeWorkload}
+
+	added := make(map[string]bool)
+	hasInbound := make(map[string]bool)
+	for _, edge := range edges {
+		hasInbound[strings.Fields(edge)[1]] = true
+	}
+
+	addNode := func(id string) {
+		if added[id] {
+			return
+		}
+		added[id] = true
+
+		node := &graph.NodeData{ID: id, NodeType: NodeTypeWorkload, Workload: id, IsRoot: !hasInbound[id]}
+		if id == NodeTypeUnknown {
+			node.NodeType = NodeTypeUnknown
+		}
+		conf.Elements.Nodes = append(conf.Elements.Nodes, &graph.NodeWrapper{Data: node})
+	}
+
+	for i, edge := range edges {
+		f := strings.Fields(edge)
+		addNode(f[0])
+		addNode(f[1])
+		conf.Elements.Edges = append(conf.Elements.Edges, &graph.EdgeWrapper{Data: &graph.EdgeData{
+			ID:           fmt.Sprintf("e%d", i),
+			Source:       f[0],
+			Target:       f[1],
+			ResponseTime: f[2],
+			Traffic: graph.ProtocolTraffic{
+				Protocol: "http",
+				Rates:    map[string]string{"http": "1.00"},
+			},
+		}})
+	}
+
+	return conf
+}
+
+// testGraph returns the graph with the given edges, see testConfig
+func testGraph(edges ...string) Graph {
+	g, _ := MakeGraph(testConfig(edges...))
+	return g
+}
+
+// diamond calls d from a both through b and through c, c being the slower
+func diamond() Graph {
+	return testGraph(
+		"unknown a 5",
+		"a b 10",
+		"a c 30",
+		"b d 10",
+		"c d 5",
+	)
+}
+
+// cyclic has b and c calling each other, and d calling itself
+func cyclic() Graph {
+	return testGraph(
+		"unknown a 5",
+		"a b 10",
+		"b c 10",
+		"c b 10",
+		"c d 20",
+		"d d 1",
+	)
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	cases := []struct {
+		name string
+		g    Graph
+		want [][]string
+	}{
+		{"diamond", diamond(), [][]string{{"d"}, {"b"}, {"c"}, {"a"}, {"unknown"}}},
+		{"cycle", cyclic(), [][]string{{"d"}, {"b", "c"}, {"a"}, {"unknown"}}},
+		{"empty", Graph{}, [][]string{}},
+	}
+
+	for _, c := range cases {
+		if got := c.g.StronglyConnectedComponents(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected components %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	cases := []struct {
+		name string
+		g    Graph
+		want [][]string
+	}{
+		{"diamond", diamond(), [][]string{}},
+		{"cycle", cyclic(), [][]string{{"d"}, {"b", "c"}}},
+	}
+
+	for _, c := range cases {
+		if got := c.g.Cycles(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected cycles %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	cases := []struct {
+		name string
+		g    Graph
+		want []string
+	}{
+		{"diamond", diamond(), []string{"unknown", "a", "c", "b", "d"}},
+		{"cycle", cyclic(), []string{"unknown", "a", "b", "c", "d"}},
+	}
+
+	for _, c := range cases {
+		if got := c.g.TopologicalOrder(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected order %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestReachable(t *testing.T) {
+	cases := []struct {
+		name string
+		g    Graph
+		from []string
+		want []string
+	}{
+		{"diamond from b", diamond(), []string{"b"}, []string{"b", "d"}},
+		{"diamond from entries", diamond(), []string{"unknown"}, []string{"a", "b", "c", "d", "unknown"}},
+		{"cycle from c", cyclic(), []string{"c"}, []string{"b", "c", "d"}},
+		{"missing node", diamond(), []string{"x"}, []string{}},
+	}
+
+	for _, c := range cases {
+		if got := sortedKeys(c.g.Reachable(c.from)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v reached, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		g        Graph
+		entries  []string
+		wantPath []string
+		wantTime float64
+	}{
+		// The slower branch through c wins
+		{"diamond", diamond(), []string{"unknown"}, []string{"unknown", "a", "c", "d"}, 40},
+		{"diamond from a", diamond(), []string{"a"}, []string{"a", "c", "d"}, 35},
+		{"diamond from b", diamond(), []string{"b"}, []string{"b", "d"}, 10},
+		// Edges within the cycle of b and c are not followed
+		{"cycle", cyclic(), []string{"unknown"}, []string{"unknown", "a", "b"}, 15},
+		{"no entries", diamond(), nil, nil, 0},
+		{"missing entry", diamond(), []string{"x"}, nil, 0},
+	}
+
+	for _, c := range cases {
+		path, responseTime := c.g.CriticalPath(c.entries)
+		if !reflect.DeepEqual(path, c.wantPath) || responseTime != c.wantTime {
+			t.Errorf("%s: expected %v taking %vms, got %v taking %vms", c.name, c.wantPath, c.wantTime, path, responseTime)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package kiali
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Authentication strategies supported by the kiali client
+const (
+	StrategyAnonymous = "anonymous"
+	StrategyBearer    = "bearer"
+	StrategyBasic     = "basic"
+	StrategyLogin     = "login"
+)
+
+// Environment variables credentials are read from when not configured
+const (
+	envToken    = "KIALI_TOKEN"
+	envUsername = "KIALI_USERNAME"
+	envPassword = "KIALI_PASSWORD"
+)
+
+// Auth holds TLS and credential details used to talk to kiali.
+//
+// Strategy is one of "anonymous" (the default), "bearer" to send Token as a
+// bearer token on every request, "basic" to send Username and Password as
+// basic auth on every request, or "login" to log in once through kiali's
+// authenticate endpoint and carry the session cookie it hands back. Logging
+// in uses Username and Password if set and Token otherwise.
+//
+// Token and Password are read from TokenFile and PasswordFile if set, and
+// otherwise fall back to the KIALI_TOKEN, KIALI_USERNAME and KIALI_PASSWORD
+// environment variables. TokenFile is read again on every request so that
+// rotated service account tokens are picked up.
+type Auth struct {
+	Strategy           string `json:"strategy"`
+	CAFile             string `json:"caFile"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	Token              string `json:"token"`
+	TokenFile          string `json:"tokenFile"`
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	PasswordFile       string `json:"passwordFile"`
+}
+
+// newHTTPClient builds an http client with the TLS settings of auth, and a
+// cookie jar to hold login sessions.
+func newHTTPClient(auth Auth) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: auth.InsecureSkipVerify,
+	}
+
+	if auth.CAFile != "" {
+		bs, err := ioutil.ReadFile(auth.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", auth.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport, Jar: jar}, nil
+}
+
+// resolveAuth fills in credentials from files and the environment.
+func resolveAuth(auth Auth) (Auth, error) {
+	if auth.Strategy == "" {
+		auth.Strategy = StrategyAnonymous
+	}
+
+	switch auth.Strategy {
+	case StrategyAnonymous, StrategyBearer, StrategyBasic, StrategyLogin:
+	default:
+		return auth, fmt.Errorf("unknown kiali auth strategy %q", auth.Strategy)
+	}
+
+	if auth.PasswordFile != "" {
+		bs, err := ioutil.ReadFile(auth.PasswordFile)
+		if err != nil {
+			return auth, err
+		}
+		auth.Password = strings.TrimSpace(string(bs))
+	}
+
+	if auth.Token == "" && auth.TokenFile == "" {
+		auth.Token = os.Getenv(envToken)
+	}
+	if auth.Username == "" {
+		auth.Username = os.Getenv(envUsername)
+	}
+	if auth.Password == "" {
+		auth.Password = os.Getenv(envPassword)
+	}
+
+	return auth, nil
+}
+
+// getToken returns the bearer token to use, reading it from file if needed.
+func (kc *Client) getToken() (string, error) {
+	if kc.auth.TokenFile == "" {
+		return kc.auth.Token, nil
+	}
+
+	bs, err := ioutil.ReadFile(kc.auth.TokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// authorize sets the credentials of the client's strategy on a request.
+func (kc *Client) authorize(req *http.Request) error {
+	switch kc.auth.Strategy {
+	case StrategyBearer:
+		token, err := kc.getToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+	case StrategyBasic:
+		req.SetBasicAuth(kc.auth.Username, kc.auth.Password)
+	}
+
+	return nil
+}
+
+// ensureLogin logs in unless a session is already held, or always if force
+// is set. It does nothing for strategies other than "login".
+func (kc *Client) ensureLogin(ctx context.Context, force bool) error {
+	if kc.auth.Strategy != StrategyLogin {
+		return nil
+	}
+
+	kc.loginMu.Lock()
+	defer kc.loginMu.Unlock()
+
+	if kc.loggedIn && !force {
+		return nil
+	}
+
+	return kc.login(ctx)
+}
+
+// login authenticates against kiali and keeps the session cookie it hands
+// back in the client's cookie jar.
+func (kc *Client) login(ctx context.Context) error {
+	u := kc.url("kiali/api/authenticate")
+
+	var req *http.Request
+	var err error
+	if kc.auth.Username != "" {
+		req, err = http.NewRequestWithContext(ctx, "POST", u.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(kc.auth.Username, kc.auth.Password)
+	} else {
+		var token string
+		token, err = kc.getToken()
+		if err != nil {
+			return err
+		}
+
+		form := url.Values{"token": []string{token}}
+		req, err = http.NewRequestWithContext(ctx, "POST", u.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := kc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kiali login failed: %s", resp.Status)
+	}
+
+	kc.loggedIn = true
+	return nil
+}
@@ -0,0 +1,333 @@
+package kiali
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const healthPath = "/kiali/api/namespaces/default/health"
+
+// newTLSServer starts a TLS server answering health requests with an empty
+// health map once authorized says so, and 401 otherwise
+func newTLSServer(t *testing.T, authorized func(r *http.Request) bool) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != healthPath {
+			http.NotFound(w, r)
+			return
+		}
+		if !authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// newTestClient returns a client of the server built from auth, which fails
+// every request at the first attempt
+func newTestClient(t *testing.T, srv *httptest.Server, auth Auth) (*Client, error) {
+	t.Helper()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kc, err := NewKialiClient("https", host, port, nil, auth)
+	if err != nil {
+		return nil, err
+	}
+	kc.SetRetryPolicy(0, 0)
+
+	return kc, nil
+}
+
+// writeFile writes contents to a file in a temporary directory and returns
+// its path
+func writeFile(t *testing.T, name string, contents []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeCA writes the certificate of a server as a PEM bundle
+func writeCA(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	return writeFile(t, "ca.crt", pem.EncodeToMemory(block))
+}
+
+func anyone(*http.Request) bool { return true }
+
+func getHealth(kc *Client) error {
+	_, err := kc.GetWorkloadHealth(context.Background(), "default", 0)
+	return err
+}
+
+func TestCustomCA(t *testing.T) {
+	srv := newTLSServer(t, anyone)
+
+	kc, err := newTestClient(t, srv, Auth{CAFile: writeCA(t, srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected the server to be trusted through the CA bundle, got %v", err)
+	}
+}
+
+// writeOtherCA writes a self-signed certificate, unrelated to the one of any
+// test server, as a PEM bundle
+func writeOtherCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeFile(t, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCustomCAOfOtherServer(t *testing.T) {
+	srv := newTLSServer(t, anyone)
+
+	kc, err := newTestClient(t, srv, Auth{CAFile: writeOtherCA(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := getHealth(kc); err == nil {
+		t.Fatal("expected a certificate signed by another CA to be rejected")
+	}
+}
+
+func TestBadCABundle(t *testing.T) {
+	srv := newTLSServer(t, anyone)
+
+	_, err := newTestClient(t, srv, Auth{CAFile: writeFile(t, "ca.crt", []byte("not a certificate"))})
+	if err == nil {
+		t.Fatal("expected a CA bundle without certificates to be rejected")
+	}
+
+	_, err = newTestClient(t, srv, Auth{CAFile: filepath.Join(t.TempDir(), "missing.crt")})
+	if err == nil {
+		t.Fatal("expected a missing CA bundle to be rejected")
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	srv := newTLSServer(t, anyone)
+
+	kc, err := newTestClient(t, srv, Auth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); err == nil {
+		t.Fatal("expected an unknown certificate to be rejected")
+	}
+
+	kc, err = newTestClient(t, srv, Auth{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected verification to be skipped, got %v", err)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	srv := newTLSServer(t, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	})
+
+	kc, err := newTestClient(t, srv, Auth{Strategy: StrategyBearer, Token: "secret", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected the token to be accepted, got %v", err)
+	}
+
+	tokenFile := writeFile(t, "token", []byte("secret\n"))
+	kc, err = newTestClient(t, srv, Auth{Strategy: StrategyBearer, TokenFile: tokenFile, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected the token read from file to be accepted, got %v", err)
+	}
+
+	kc, err = newTestClient(t, srv, Auth{Strategy: StrategyBearer, Token: "wrong", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized for a wrong token, got %v", err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := newTLSServer(t, func(r *http.Request) bool {
+		username, password, ok := r.BasicAuth()
+		return ok && username == "admin" && password == "secret"
+	})
+
+	passwordFile := writeFile(t, "password", []byte("secret\n"))
+	kc, err := newTestClient(t, srv, Auth{Strategy: StrategyBasic, Username: "admin", PasswordFile: passwordFile, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected the credentials to be accepted, got %v", err)
+	}
+
+	kc, err = newTestClient(t, srv, Auth{Strategy: StrategyBasic, Username: "admin", Password: "wrong", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := getHealth(kc); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized for a wrong password, got %v", err)
+	}
+}
+
+// sessionServer hands out a session cookie for the right token and accepts
+// requests carrying the current session
+type sessionServer struct {
+	mu      sync.Mutex
+	logins  int
+	session string
+}
+
+func (s *sessionServer) handler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/kiali/api/authenticate":
+		if r.Method != "POST" || r.FormValue("token") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.logins++
+		s.session = "session-" + strconv.Itoa(s.logins)
+		http.SetCookie(w, &http.Cookie{Name: "kiali-token", Value: s.session, Path: "/"})
+
+	case healthPath:
+		cookie, err := r.Cookie("kiali-token")
+		if err != nil || cookie.Value != s.session {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// expire drops the current session
+func (s *sessionServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.session = "expired"
+}
+
+func TestLoginSession(t *testing.T) {
+	sessions := &sessionServer{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(sessions.handler))
+	t.Cleanup(srv.Close)
+
+	kc, err := newTestClient(t, srv, Auth{Strategy: StrategyLogin, Token: "secret", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := getHealth(kc); err != nil {
+			t.Fatalf("request %d: expected the session to be accepted, got %v", i, err)
+		}
+	}
+	if sessions.logins != 1 {
+		t.Fatalf("expected a single login for both requests, got %d", sessions.logins)
+	}
+
+	// An expired session is renewed once
+	sessions.expire()
+	if err := getHealth(kc); err != nil {
+		t.Fatalf("expected the session to be renewed, got %v", err)
+	}
+	if sessions.logins != 2 {
+		t.Fatalf("expected a second login after the session expired, got %d", sessions.logins)
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	sessions := &sessionServer{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(sessions.handler))
+	t.Cleanup(srv.Close)
+
+	kc, err := newTestClient(t, srv, Auth{Strategy: StrategyLogin, Token: "wrong", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := getHealth(kc); err == nil {
+		t.Fatal("expected a rejected login to fail the request")
+	}
+	if sessions.logins != 0 {
+		t.Fatalf("expected no session to be handed out, got %d", sessions.logins)
+	}
+}
@@ -0,0 +1,93 @@
+package kiali
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GraphSource is anything graphs can be fetched from, such as a Client
+type GraphSource interface {
+	GetGraph(ctx context.Context, query GraphQuery) (Graph, error)
+}
+
+// GraphCache shares graphs fetched from a source between concurrent callers. A
+// graph is served from the cache for ttl after it was fetched, and callers
+// asking for a graph already being fetched wait for that fetch instead of
+// sending their own.
+type GraphCache struct {
+	source GraphSource
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]graphEntry
+	inflight map[string]*graphCall
+}
+
+type graphEntry struct {
+	graph   Graph
+	fetched time.Time
+}
+
+type graphCall struct {
+	done  chan struct{}
+	graph Graph
+	err   error
+}
+
+// NewGraphCache is a constructor for type GraphCache
+func NewGraphCache(source GraphSource, ttl time.Duration) *GraphCache {
+	return &GraphCache{
+		source:   source,
+		ttl:      ttl,
+		entries:  make(map[string]graphEntry),
+		inflight: make(map[string]*graphCall),
+	}
+}
+
+// GetGraph gives the graph described by a query, from the cache if it was
+// fetched less than ttl ago. Graphs are shared between callers and must not be
+// modified.
+func (c *GraphCache) GetGraph(ctx context.Context, query GraphQuery) (Graph, error) {
+	key, err := query.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && time.Since(entry.fetched) < c.ttl {
+		c.mu.Unlock()
+		return entry.graph, nil
+	}
+
+	call, ok := c.inflight[key]
+	if !ok {
+		call = &graphCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		go c.fetch(key, query, call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.graph, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch gets a graph for everyone waiting on call. It is not tied to the
+// context of any one caller so that a caller giving up does not fail the
+// others.
+func (c *GraphCache) fetch(key string, query GraphQuery, call *graphCall) {
+	call.graph, call.err = c.source.GetGraph(context.Background(), query)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.entries[key] = graphEntry{graph: call.graph, fetched: time.Now()}
+	}
+	c.mu.Unlock()
+
+	close(call.done)
+}
@@ -5,25 +5,56 @@ import (
 )
 
 func newItem(node *graph.NodeData) Item {
-	return Item{Node: node}
+	nodeType := node.NodeType
+	if nodeType == "" {
+		nodeType = NodeTypeWorkload
+	}
+
+	return Item{
+		Node:             node,
+		Type:             nodeType,
+		IsRoot:           node.IsRoot,
+		IsOutside:        node.IsOutside,
+		IsServiceEntry:   node.IsServiceEntry != nil,
+		IsIngressGateway: node.IsGateway != nil,
+		IsIdle:           node.IsIdle,
+	}
 }
 
-// MakeGraph returns a Graph variable from a given graphType
-func MakeGraph(graphType *graph.Config) Graph {
+// MakeGraph returns a Graph variable from a given graphType. Nodes without an
+// ID and edges missing either end are left out, so that a truncated or
+// filtered response still gives the part of the graph that holds together.
+// The edges left out for a missing end are returned as dangling.
+func MakeGraph(graphType *graph.Config) (Graph, []*graph.EdgeData) {
 	adjList := make(Graph)
+	dangling := []*graph.EdgeData{}
+
 	for _, node := range graphType.Elements.Nodes {
+		if node == nil || node.Data == nil || node.Data.ID == "" {
+			continue
+		}
+
 		item := newItem(node.Data)
 		adjList[node.Data.ID] = &item
 	}
 
 	for _, edge := range graphType.Elements.Edges {
-		if adjList[edge.Data.Source].Edges != nil {
-			adjList[edge.Data.Source].Edges = append(adjList[edge.Data.Source].Edges, edge.Data)
-		} else {
-			adjList[edge.Data.Source].Edges = make([]*graph.EdgeData, 0)
-			adjList[edge.Data.Source].Edges = append(adjList[edge.Data.Source].Edges, edge.Data)
+		if edge == nil || edge.Data == nil {
+			continue
+		}
+
+		source, ok := adjList[edge.Data.Source]
+		if !ok {
+			dangling = append(dangling, edge.Data)
+			continue
 		}
+		if _, ok := adjList[edge.Data.Target]; !ok {
+			dangling = append(dangling, edge.Data)
+			continue
+		}
+
+		source.Edges = append(source.Edges, edge.Data)
 	}
 
-	return adjList
+	return adjList, dangling
 }
@@ -0,0 +1,85 @@
+package kiali
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+func TestMakeGraph(t *testing.T) {
+	conf := testConfig(
+		"unknown a 5",
+		"a b 10",
+		"b c 10",
+	)
+
+	// Nodes left out of a truncated response, and edges missing either end
+	conf.Elements.Nodes = append(conf.Elements.Nodes,
+		nil,
+		&graph.NodeWrapper{},
+		&graph.NodeWrapper{Data: &graph.NodeData{Workload: "noid"}},
+		&graph.NodeWrapper{Data: &graph.NodeData{ID: "d"}},
+	)
+	conf.Elements.Edges = append(conf.Elements.Edges,
+		nil,
+		&graph.EdgeWrapper{},
+		&graph.EdgeWrapper{Data: &graph.EdgeData{ID: "nosource", Source: "x", Target: "a"}},
+		&graph.EdgeWrapper{Data: &graph.EdgeData{ID: "notarget", Source: "b", Target: "y"}},
+		&graph.EdgeWrapper{Data: &graph.EdgeData{ID: "noid", Source: "c", Target: ""}},
+		&graph.EdgeWrapper{Data: &graph.EdgeData{ID: "cd", Source: "c", Target: "d"}},
+	)
+
+	g, dangling := MakeGraph(conf)
+
+	nodes := make([]string, 0, len(g))
+	for id := range g {
+		nodes = append(nodes, id)
+	}
+	sort.Strings(nodes)
+	if want := []string{"a", "b", "c", "d", "unknown"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("expected nodes %v, got %v", want, nodes)
+	}
+
+	ids := []string{}
+	for _, edge := range dangling {
+		ids = append(ids, edge.ID)
+	}
+	if want := []string{"nosource", "notarget", "noid"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected dangling edges %v, got %v", want, ids)
+	}
+
+	cases := []struct {
+		source  string
+		targets []string
+	}{
+		{NodeTypeUnknown, []string{"a"}},
+		{"a", []string{"b"}},
+		{"b", []string{"c"}},
+		{"c", []string{"d"}},
+		{"d", []string{}},
+	}
+
+	for _, c := range cases {
+		targets := []string{}
+		for _, edge := range g[c.source].Edges {
+			targets = append(targets, edge.Target)
+		}
+		if !reflect.DeepEqual(targets, c.targets) {
+			t.Errorf("%s: expected edges to %v, got %v", c.source, c.targets, targets)
+		}
+	}
+
+	// Nodes without a type are taken to be workloads
+	if g["d"].Type != NodeTypeWorkload {
+		t.Errorf("d: expected type %s, got %s", NodeTypeWorkload, g["d"].Type)
+	}
+}
+
+func TestMakeGraphEmpty(t *testing.T) {
+	g, dangling := MakeGraph(&graph.Config{})
+	if len(g) != 0 || len(dangling) != 0 {
+		t.Fatalf("expected an empty graph, got %d nodes and %d dangling edges", len(g), len(dangling))
+	}
+}
@@ -0,0 +1,222 @@
+package kiali
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metrics of an edge compared between graphs
+const (
+	DiffRate      = "rate"
+	DiffLatency   = "latency"
+	DiffErrorRate = "error rate"
+)
+
+// DiffThresholds sets how much the metrics of an edge must change for a diff
+// to report them. Rate and Latency are relative changes, ErrorRate is a change
+// in percentage points.
+type DiffThresholds struct {
+	Rate      float64 `json:"rate"`
+	Latency   float64 `json:"latency"`
+	ErrorRate float64 `json:"errorRate"`
+}
+
+// DefaultDiffThresholds report rates or latencies changing by a fifth and
+// error rates changing by a percentage point
+var DefaultDiffThresholds = DiffThresholds{
+	Rate:      0.2,
+	Latency:   0.2,
+	ErrorRate: 1,
+}
+
+// EdgeKey identifies an edge across graphs by the names of its nodes and its
+// protocol
+type EdgeKey struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Protocol string `json:"protocol"`
+}
+
+func (k EdgeKey) String() string {
+	if k.Protocol == "" {
+		return fmt.Sprintf("%s -> %s", k.Source, k.Target)
+	}
+	return fmt.Sprintf("%s -> %s (%s)", k.Source, k.Target, k.Protocol)
+}
+
+// EdgeChange is a metric of an edge found in both graphs which changed
+// beyond its threshold
+type EdgeChange struct {
+	Edge   EdgeKey `json:"edge"`
+	Metric string  `json:"metric"`
+	Old    float64 `json:"old"`
+	New    float64 `json:"new"`
+}
+
+// GraphDiff holds the differences between two graphs. Nodes are compared by
+// name, see Item.Name, and edges by EdgeKey.
+type GraphDiff struct {
+	AddedNodes   []string     `json:"addedNodes"`
+	RemovedNodes []string     `json:"removedNodes"`
+	AddedEdges   []EdgeKey    `json:"addedEdges"`
+	RemovedEdges []EdgeKey    `json:"removedEdges"`
+	Changes      []EdgeChange `json:"changes"`
+}
+
+// TopologyChanged reports whether nodes or edges were added or removed
+func (d GraphDiff) TopologyChanged() bool {
+	return len(d.AddedNodes) > 0 || len(d.RemovedNodes) > 0 || len(d.AddedEdges) > 0 || len(d.RemovedEdges) > 0
+}
+
+// Empty reports whether the graphs did not differ at all
+func (d GraphDiff) Empty() bool {
+	return !d.TopologyChanged() && len(d.Changes) == 0
+}
+
+// String lists the differences one per line
+func (d GraphDiff) String() string {
+	lines := []string{}
+	for _, node := range d.AddedNodes {
+		lines = append(lines, "+ node "+node)
+	}
+	for _, node := range d.RemovedNodes {
+		lines = append(lines, "- node "+node)
+	}
+	for _, edge := range d.AddedEdges {
+		lines = append(lines, "+ edge "+edge.String())
+	}
+	for _, edge := range d.RemovedEdges {
+		lines = append(lines, "- edge "+edge.String())
+	}
+	for _, change := range d.Changes {
+		lines = append(lines, fmt.Sprintf("~ edge %s %s: %g -> %g", change.Edge, change.Metric, change.Old, change.New))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// edgeMetrics are the metrics of an edge compared between graphs
+type edgeMetrics struct {
+	rate      float64
+	latency   float64
+	errorRate float64
+}
+
+// edgeIndex returns the metrics of every edge of a graph by key. Parallel
+// edges are merged, summing their rates and keeping the worst latency and
+// error rate.
+func (g Graph) edgeIndex() map[EdgeKey]edgeMetrics {
+	index := make(map[EdgeKey]edgeMetrics)
+	for _, item := range g {
+		for _, edge := range item.Edges {
+			target, ok := g[edge.Target]
+			if !ok {
+				continue
+			}
+
+			protocol := edge.Traffic.Protocol
+			key := EdgeKey{Source: item.Name(), Target: target.Name(), Protocol: protocol}
+
+			m := index[key]
+			m.rate += parseMetric(edge.Traffic.Rates[protocol])
+			m.latency = math.Max(m.latency, parseMetric(edge.ResponseTime))
+			m.errorRate = math.Max(m.errorRate, parseMetric(edge.Traffic.Rates[protocol+"PercentErr"]))
+			index[key] = m
+		}
+	}
+
+	return index
+}
+
+func parseMetric(value string) float64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// relativeChange reports whether new differs from old by more than threshold
+// relative to old. Anything differs from zero.
+func relativeChange(old, new, threshold float64) bool {
+	if old == 0 {
+		return new != 0
+	}
+	return math.Abs(new-old)/old > threshold
+}
+
+// Diff compares two graphs and returns what changed from old to new
+func Diff(old, new Graph, thresholds DiffThresholds) GraphDiff {
+	d := GraphDiff{
+		AddedNodes:   []string{},
+		RemovedNodes: []string{},
+		AddedEdges:   []EdgeKey{},
+		RemovedEdges: []EdgeKey{},
+		Changes:      []EdgeChange{},
+	}
+
+	oldNodes, newNodes := make(map[string]bool), make(map[string]bool)
+	for _, item := range old {
+		oldNodes[item.Name()] = true
+	}
+	for _, item := range new {
+		newNodes[item.Name()] = true
+	}
+
+	for name := range newNodes {
+		if !oldNodes[name] {
+			d.AddedNodes = append(d.AddedNodes, name)
+		}
+	}
+	for name := range oldNodes {
+		if !newNodes[name] {
+			d.RemovedNodes = append(d.RemovedNodes, name)
+		}
+	}
+
+	oldEdges, newEdges := old.edgeIndex(), new.edgeIndex()
+	for key, n := range newEdges {
+		o, ok := oldEdges[key]
+		if !ok {
+			d.AddedEdges = append(d.AddedEdges, key)
+			continue
+		}
+
+		if relativeChange(o.rate, n.rate, thresholds.Rate) {
+			d.Changes = append(d.Changes, EdgeChange{Edge: key, Metric: DiffRate, Old: o.rate, New: n.rate})
+		}
+		if relativeChange(o.latency, n.latency, thresholds.Latency) {
+			d.Changes = append(d.Changes, EdgeChange{Edge: key, Metric: DiffLatency, Old: o.latency, New: n.latency})
+		}
+		if math.Abs(n.errorRate-o.errorRate) > thresholds.ErrorRate {
+			d.Changes = append(d.Changes, EdgeChange{Edge: key, Metric: DiffErrorRate, Old: o.errorRate, New: n.errorRate})
+		}
+	}
+	for key := range oldEdges {
+		if _, ok := newEdges[key]; !ok {
+			d.RemovedEdges = append(d.RemovedEdges, key)
+		}
+	}
+
+	sort.Strings(d.AddedNodes)
+	sort.Strings(d.RemovedNodes)
+	sortEdgeKeys(d.AddedEdges)
+	sortEdgeKeys(d.RemovedEdges)
+	sort.Slice(d.Changes, func(i, j int) bool {
+		if d.Changes[i].Edge != d.Changes[j].Edge {
+			return d.Changes[i].Edge.String() < d.Changes[j].Edge.String()
+		}
+		return d.Changes[i].Metric < d.Changes[j].Metric
+	})
+
+	return d
+}
+
+func sortEdgeKeys(keys []EdgeKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+}
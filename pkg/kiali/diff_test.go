@@ -0,0 +1,98 @@
+package kiali
+
+import (
+	"reflect"
+	"testing"
+)
+
+// setTraffic sets the request rate and percentage of failed requests of the
+// edge from source to target
+func setTraffic(t *testing.T, g Graph, source, target, rate, percentErr string) {
+	t.Helper()
+
+	for _, edge := range g.Outbound(source) {
+		if edge.Target == target {
+			edge.Traffic.Rates = map[string]string{"http": rate, "httpPercentErr": percentErr}
+			return
+		}
+	}
+
+	t.Fatalf("no edge from %s to %s", source, target)
+}
+
+func httpEdge(source, target string) EdgeKey {
+	return EdgeKey{Source: source, Target: target, Protocol: "http"}
+}
+
+func TestDiff(t *testing.T) {
+	old := testGraph(
+		"unknown a 5",
+		"a b 10",
+		"a c 30",
+		"a x 1",
+		"b d 10",
+		"c d 5",
+	)
+	new := testGraph(
+		"unknown a 5",
+		"a b 11",
+		"a c 40",
+		"b d 10",
+		"b e 3",
+	)
+
+	// Rate up by half, error rates up by half a point and by 2.5 points
+	setTraffic(t, new, "a", "b", "1.50", "0")
+	setTraffic(t, new, "unknown", "a", "1.00", "0.5")
+	setTraffic(t, new, "b", "d", "1.00", "2.5")
+
+	d := Diff(old, new, DefaultDiffThresholds)
+
+	if want := []string{"e"}; !reflect.DeepEqual(d.AddedNodes, want) {
+		t.Errorf("expected added nodes %v, got %v", want, d.AddedNodes)
+	}
+	if want := []string{"x"}; !reflect.DeepEqual(d.RemovedNodes, want) {
+		t.Errorf("expected removed nodes %v, got %v", want, d.RemovedNodes)
+	}
+	if want := []EdgeKey{httpEdge("b", "e")}; !reflect.DeepEqual(d.AddedEdges, want) {
+		t.Errorf("expected added edges %v, got %v", want, d.AddedEdges)
+	}
+	if want := []EdgeKey{httpEdge("a", "x"), httpEdge("c", "d")}; !reflect.DeepEqual(d.RemovedEdges, want) {
+		t.Errorf("expected removed edges %v, got %v", want, d.RemovedEdges)
+	}
+
+	// The latency of a to b and the error rate of unknown to a stay within
+	// their thresholds
+	want := []EdgeChange{
+		{Edge: httpEdge("a", "b"), Metric: DiffRate, Old: 1, New: 1.5},
+		{Edge: httpEdge("a", "c"), Metric: DiffLatency, Old: 30, New: 40},
+		{Edge: httpEdge("b", "d"), Metric: DiffErrorRate, Old: 0, New: 2.5},
+	}
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Errorf("expected changes %v, got %v", want, d.Changes)
+	}
+
+	if !d.TopologyChanged() || d.Empty() {
+		t.Error("expected the topology to have changed")
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	d := Diff(diamond(), diamond(), DefaultDiffThresholds)
+	if !d.Empty() || d.TopologyChanged() {
+		t.Fatalf("expected no differences between equal graphs, got:\n%s", d)
+	}
+	if d.String() != "" {
+		t.Fatalf("expected an empty listing, got %q", d.String())
+	}
+}
+
+func TestDiffString(t *testing.T) {
+	old := testGraph("unknown a 10")
+	new := testGraph("unknown a 20", "a b 5")
+
+	want := "+ node b\n+ edge a -> b (http)\n~ edge unknown -> a (http) latency: 10 -> 20"
+	if got := Diff(old, new, DefaultDiffThresholds).String(); got != want {
+		t.Fatalf("unexpected listing:\n%s\nwant:\n%s", got, want)
+	}
+}
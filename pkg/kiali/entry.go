@@ -0,0 +1,91 @@
+package kiali
+
+import (
+	"sort"
+	"strconv"
+
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// EntrySelector picks the nodes through which traffic enters an application.
+// Sources names the entry nodes, e.g. an ingress gateway workload or specific
+// client workloads. Otherwise, with Gateways set, the ingress gateways of the
+// graph are the entry nodes, and by default every unknown and root node is.
+type EntrySelector struct {
+	Sources  []string `json:"sources"`
+	Gateways bool     `json:"gateways"`
+}
+
+// SelectEntries returns the IDs of the entry nodes of a graph, sorted
+func (g Graph) SelectEntries(selector EntrySelector) []string {
+	if len(selector.Sources) == 0 && !selector.Gateways {
+		return g.EntryNodes()
+	}
+
+	sources := make(map[string]bool)
+	for _, source := range selector.Sources {
+		sources[source] = true
+	}
+
+	ids := []string{}
+	for id, item := range g {
+		if len(sources) > 0 && sources[item.Name()] {
+			ids = append(ids, id)
+		} else if len(sources) == 0 && item.IsIngressGateway {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// EntryEdges returns the edges carrying traffic into an application by the
+// ID of the entry node they leave, i.e. the edges from every entry node to
+// nodes which are not entry nodes. Traffic passing from one entry node to
+// another, e.g. from the unknown node to an ingress gateway, is counted once,
+// where it leaves the entry set.
+func (g Graph) EntryEdges(entries []string) map[string][]*graph.EdgeData {
+	isEntry := make(map[string]bool)
+	for _, id := range entries {
+		isEntry[id] = true
+	}
+
+	edges := make(map[string][]*graph.EdgeData)
+	for _, id := range entries {
+		for _, edge := range g.Outbound(id) {
+			if !isEntry[edge.Target] {
+				edges[id] = append(edges[id], edge)
+			}
+		}
+	}
+
+	return edges
+}
+
+// EntryThroughputs returns the throughput entering an application through
+// every entry node, by node name, in bytes per second
+func (g Graph) EntryThroughputs(entries []string) map[string]int64 {
+	throughputs := make(map[string]int64)
+	for id, edges := range g.EntryEdges(entries) {
+		for _, edge := range edges {
+			t, _ := strconv.ParseInt(edge.Throughput, 10, 64)
+			throughputs[g[id].Name()] += t
+		}
+	}
+
+	return throughputs
+}
+
+// EntryRequestRates returns the HTTP request rate entering an application
+// through every entry node, by node name, in requests per second
+func (g Graph) EntryRequestRates(entries []string) map[string]float64 {
+	rates := make(map[string]float64)
+	for id, edges := range g.EntryEdges(entries) {
+		for _, edge := range edges {
+			rates[g[id].Name()] += parseMetric(edge.Traffic.Rates["http"])
+		}
+	}
+
+	return rates
+}
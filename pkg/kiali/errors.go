@@ -0,0 +1,94 @@
+package kiali
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the kiali client. They are wrapped in a RequestError, so
+// callers should compare against them with errors.Is.
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrNotFound         = errors.New("not found")
+	ErrServerError      = errors.New("server error")
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	ErrDecode           = errors.New("could not decode response")
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// maxErrorBody is how much of an error response body is kept in a RequestError
+const maxErrorBody = 256
+
+// RequestError is returned for requests kiali answered with an error status,
+// or whose response could not be used.
+type RequestError struct {
+	URL        string
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("kiali request to %s failed with status %d: %v", e.URL, e.StatusCode, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError returns the RequestError for a response with a non 2xx status
+func newStatusError(url string, statusCode int, body []byte) error {
+	var err error
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		err = ErrUnauthorized
+	case statusCode == http.StatusNotFound:
+		err = ErrNotFound
+	case statusCode >= 500:
+		err = ErrServerError
+	default:
+		err = ErrUnexpectedStatus
+	}
+
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+
+	return &RequestError{
+		URL:        url,
+		StatusCode: statusCode,
+		Body:       string(body),
+		Err:        err,
+	}
+}
+
+// newDecodeError returns the RequestError for a response body that could not
+// be decoded
+func newDecodeError(url string, err error) error {
+	return &RequestError{
+		URL:        url,
+		StatusCode: http.StatusOK,
+		Err:        fmt.Errorf("%w: %v", ErrDecode, err),
+	}
+}
+
+// isTransient reports whether a request that failed with err may succeed when
+// retried. Server errors, rate limiting and transport errors are transient,
+// other error statuses, certificate errors and unusable responses are not.
+func isTransient(err error) bool {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.StatusCode >= 500 || requestErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certificateInvalidErr) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,212 @@
+package kiali
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats a graph can be exported in
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+// NodeAnnotation holds what the autoscaler knows about a node, to be shown
+// alongside it. Thresholds of 0 are not set, and Decision describes what the
+// last scaling cycle decided for the node.
+type NodeAnnotation struct {
+	Replicas             int     `json:"replicas"`
+	CPU                  float64 `json:"cpu"`
+	CPUThreshold         float64 `json:"cpuThreshold"`
+	QueueLength          float64 `json:"queueLength"`
+	QueueLengthThreshold float64 `json:"queueLengthThreshold"`
+	Decision             string  `json:"decision,omitempty"`
+}
+
+// Annotations holds the annotations of nodes by node name
+type Annotations map[string]NodeAnnotation
+
+// ExportedNode is a node in the stable exported form of a graph
+type ExportedNode struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	IsRoot     bool            `json:"isRoot,omitempty"`
+	IsOutside  bool            `json:"isOutside,omitempty"`
+	IsIdle     bool            `json:"isIdle,omitempty"`
+	Annotation *NodeAnnotation `json:"annotation,omitempty"`
+}
+
+// ExportedEdge is an edge in the stable exported form of a graph. Rate is in
+// requests per second and Latency in milliseconds.
+type ExportedEdge struct {
+	Source   string  `json:"source"`
+	Target   string  `json:"target"`
+	Protocol string  `json:"protocol"`
+	Rate     float64 `json:"rate"`
+	Latency  float64 `json:"latency"`
+}
+
+// ExportedGraph is the stable exported form of a graph, with nodes sorted by
+// ID and edges by source and target
+type ExportedGraph struct {
+	Nodes []ExportedNode `json:"nodes"`
+	Edges []ExportedEdge `json:"edges"`
+}
+
+// Exported returns the stable exported form of a graph with the given
+// annotations, which may be nil
+func (g Graph) Exported(annotations Annotations) ExportedGraph {
+	e := ExportedGraph{
+		Nodes: []ExportedNode{},
+		Edges: []ExportedEdge{},
+	}
+
+	for _, id := range g.sortedIDs() {
+		item := g[id]
+		node := ExportedNode{
+			ID:        id,
+			Name:      item.Name(),
+			Type:      item.Type,
+			IsRoot:    item.IsRoot,
+			IsOutside: item.IsOutside,
+			IsIdle:    item.IsIdle,
+		}
+		if a, ok := annotations[item.Name()]; ok {
+			node.Annotation = &a
+		}
+		e.Nodes = append(e.Nodes, node)
+
+		for _, edge := range item.Edges {
+			protocol := edge.Traffic.Protocol
+			e.Edges = append(e.Edges, ExportedEdge{
+				Source:   id,
+				Target:   edge.Target,
+				Protocol: protocol,
+				Rate:     parseMetric(edge.Traffic.Rates[protocol]),
+				Latency:  parseMetric(edge.ResponseTime),
+			})
+		}
+	}
+
+	sort.SliceStable(e.Edges, func(i, j int) bool {
+		if e.Edges[i].Source != e.Edges[j].Source {
+			return e.Edges[i].Source < e.Edges[j].Source
+		}
+		if e.Edges[i].Target != e.Edges[j].Target {
+			return e.Edges[i].Target < e.Edges[j].Target
+		}
+		return e.Edges[i].Protocol < e.Edges[j].Protocol
+	})
+
+	return e
+}
+
+// ValidateFormat checks that graphs can be exported in a format
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatDOT, FormatMermaid, FormatJSON:
+		return nil
+	}
+
+	return fmt.Errorf("unknown export format %q, expected %s, %s or %s", format, FormatDOT, FormatMermaid, FormatJSON)
+}
+
+// Export writes a graph with the given annotations, which may be nil, in one
+// of the "dot", "mermaid" or "json" formats
+func (g Graph) Export(w io.Writer, format string, annotations Annotations) error {
+	switch format {
+	case FormatDOT:
+		return g.ExportDOT(w, annotations)
+	case FormatMermaid:
+		return g.ExportMermaid(w, annotations)
+	case FormatJSON:
+		return g.ExportJSON(w, annotations)
+	}
+
+	return ValidateFormat(format)
+}
+
+// ExportJSON writes the stable exported form of a graph as JSON
+func (g Graph) ExportJSON(w io.Writer, annotations Annotations) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(g.Exported(annotations))
+}
+
+// ExportDOT writes a graph as a Graphviz digraph
+func (g Graph) ExportDOT(w io.Writer, annotations Annotations) error {
+	e := g.Exported(annotations)
+
+	lines := []string{"digraph G {", "  node [shape=box];"}
+	for _, node := range e.Nodes {
+		label := strings.Join(nodeLabel(node), "\n")
+		lines = append(lines, fmt.Sprintf("  %q [label=%q];", node.ID, label))
+	}
+	for _, edge := range e.Edges {
+		lines = append(lines, fmt.Sprintf("  %q -> %q [label=%q];", edge.Source, edge.Target, edgeLabel(edge)))
+	}
+	lines = append(lines, "}")
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// ExportMermaid writes a graph as a Mermaid flowchart
+func (g Graph) ExportMermaid(w io.Writer, annotations Annotations) error {
+	e := g.Exported(annotations)
+
+	// Mermaid IDs are kept to plain characters
+	ids := make(map[string]string)
+	for i, node := range e.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	lines := []string{"flowchart LR"}
+	for _, node := range e.Nodes {
+		label := strings.Join(nodeLabel(node), "<br/>")
+		lines = append(lines, fmt.Sprintf("  %s[%q]", ids[node.ID], mermaidEscape(label)))
+	}
+	for _, edge := range e.Edges {
+		lines = append(lines, fmt.Sprintf("  %s -->|%q| %s", ids[edge.Source], mermaidEscape(edgeLabel(edge)), ids[edge.Target]))
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// nodeLabel returns the lines describing a node
+func nodeLabel(node ExportedNode) []string {
+	lines := []string{node.Name}
+	a := node.Annotation
+	if a == nil {
+		return lines
+	}
+
+	lines = append(lines, fmt.Sprintf("replicas: %d", a.Replicas))
+	if a.CPUThreshold > 0 {
+		lines = append(lines, fmt.Sprintf("cpu: %.3f / %.3f", a.CPU, a.CPUThreshold))
+	}
+	if a.QueueLengthThreshold > 0 {
+		lines = append(lines, fmt.Sprintf("ql: %.1f / %.1f", a.QueueLength, a.QueueLengthThreshold))
+	}
+	if a.Decision != "" {
+		lines = append(lines, a.Decision)
+	}
+
+	return lines
+}
+
+// edgeLabel returns the text describing an edge
+func edgeLabel(edge ExportedEdge) string {
+	return fmt.Sprintf("%.2f req/s, %.1fms", edge.Rate, edge.Latency)
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
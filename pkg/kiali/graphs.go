@@ -3,33 +3,50 @@ package kiali
 import (
 	"context"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"log"
 	"strconv"
-	"strings"
 
 	graph "github.com/kiali/kiali/graph/config/cytoscape"
 )
 
-// GetWorkloadGraph gives the workload graph for a specified workload in a namespace
-func (kc *Client) GetWorkloadGraph(ctx context.Context, namespaces []string, parameters map[string]string) (Graph, error) {
+// GetWorkloadGraph gives the workload graph described by a query
+func (kc *Client) GetWorkloadGraph(ctx context.Context, query GraphQuery) (Graph, error) {
+	query.GraphType = GraphTypeWorkload
+	return kc.GetGraph(ctx, query)
+}
+
+// GetAppGraph gives the app graph described by a query, with one node per app
+// across all of its versions
+func (kc *Client) GetAppGraph(ctx context.Context, query GraphQuery) (Graph, error) {
+	query.GraphType = GraphTypeApp
+	return kc.GetGraph(ctx, query)
+}
+
+// GetVersionedAppGraph gives the versioned app graph described by a query,
+// with one node per version of an app
+func (kc *Client) GetVersionedAppGraph(ctx context.Context, query GraphQuery) (Graph, error) {
+	query.GraphType = GraphTypeVersionedApp
+	return kc.GetGraph(ctx, query)
+}
+
+// GetServiceGraph gives the service graph described by a query
+func (kc *Client) GetServiceGraph(ctx context.Context, query GraphQuery) (Graph, error) {
+	query.GraphType = GraphTypeService
+	return kc.GetGraph(ctx, query)
+}
+
+// GetGraph gives the graph described by a query
+func (kc *Client) GetGraph(ctx context.Context, query GraphQuery) (Graph, error) {
 	endpoint := "kiali/api/namespaces/graph"
-	parameters["namespaces"] = strings.Join(namespaces, ",")
 
-	// Construct base URL
-	u := &url.URL{
-		Scheme: "http",
-		Host:   kc.host,
-		Path:   endpoint,
+	// Construct URL along with query parameters
+	rawQuery, err := query.Encode()
+	if err != nil {
+		return nil, err
 	}
 
-	// Set query parameters
-	query := u.Query()
-	for parameter, value := range parameters {
-		query.Add(parameter, value)
-	}
-	u.RawQuery = query.Encode()
+	u := kc.url(endpoint)
+	u.RawQuery = rawQuery
 
 	// Send request
 	body, err := kc.sendRequest(ctx, "GET", u.String())
@@ -40,36 +57,39 @@ func (kc *Client) GetWorkloadGraph(ctx context.Context, namespaces []string, par
 	graphType := &graph.Config{}
 	err = json.Unmarshal(body, graphType)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(u.String(), err)
 	}
 
-	graph := MakeGraph(graphType)
-	return graph, nil
-}
-
-// sendRequest constructs a request, sends it and returns the response body as a string
-func (kc *Client) sendRequest(ctx context.Context, method, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
-	if err != nil {
-		return nil, err
+	graph, dangling := MakeGraph(graphType)
+	for _, edge := range dangling {
+		log.Printf("[kiali graph] dropped edge %s from %q to %q, missing its node\n", edge.ID, edge.Source, edge.Target)
 	}
 
-	resp, err := kc.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	return graph, nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// Name returns the name of a node in its graph. This is the workload name for
+// workload nodes, the service name for service nodes and the app name for app
+// nodes, suffixed with "-<version>" for the nodes of a versioned app graph.
+// Nodes for traffic from outside the mesh are named "unknown".
+func (i *Item) Name() string {
+	switch i.Type {
+	case NodeTypeUnknown:
+		return "unknown"
+	case NodeTypeApp:
+		if i.Node.Version != "" {
+			return i.Node.App + "-" + i.Node.Version
+		}
+		return i.Node.App
+	case NodeTypeService:
+		return i.Node.Service
 	}
-	defer resp.Body.Close()
 
-	return body, nil
+	return i.Node.Workload
 }
 
-// GetQueueLengths returns per workload queue lengths as a map with the
-// deployment name as key and queue length as value along with the queue length
+// GetQueueLengths returns per node queue lengths as a map with the node name
+// as key and queue length as value along with the queue length
 // for the 'unknown' node
 func (g Graph) GetQueueLengths() (map[string]float64, float64) {
 	queueLengths := make(map[string]float64)
@@ -79,7 +99,7 @@ func (g Graph) GetQueueLengths() (map[string]float64, float64) {
 
 		// Iterate over an item's edges
 		for _, edge := range item.Edges {
-			depName := g[edge.Target].Node.Workload
+			depName := g[edge.Target].Name()
 			throughput, err := strconv.ParseFloat(edge.Throughput, 64)
 			if err != nil {
 				throughput = 0
@@ -104,3 +124,28 @@ func (g Graph) GetQueueLengths() (map[string]float64, float64) {
 
 	return queueLengths, 0
 }
+
+// GetRequestRates returns per node incoming request rates as a map with the
+// node name as key and the sum of HTTP request rates of its inbound
+// edges as value
+func (g Graph) GetRequestRates() map[string]float64 {
+	requestRates := make(map[string]float64)
+
+	for _, item := range g {
+		for _, edge := range item.Edges {
+			target, ok := g[edge.Target]
+			if !ok {
+				continue
+			}
+
+			rate, err := strconv.ParseFloat(edge.Traffic.Rates["http"], 64)
+			if err != nil {
+				rate = 0
+			}
+
+			requestRates[target.Name()] += rate
+		}
+	}
+
+	return requestRates
+}
@@ -0,0 +1,158 @@
+package kiali
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Kinds of health kiali computes for a namespace
+const (
+	HealthTypeApp      = "app"
+	HealthTypeService  = "service"
+	HealthTypeWorkload = "workload"
+)
+
+// WorkloadStatus holds the replica counts of a workload. In a healthy
+// workload all of them are equal.
+type WorkloadStatus struct {
+	Name              string `json:"name"`
+	DesiredReplicas   int32  `json:"desiredReplicas"`
+	CurrentReplicas   int32  `json:"currentReplicas"`
+	AvailableReplicas int32  `json:"availableReplicas"`
+	SyncedProxies     int32  `json:"syncedProxies"`
+}
+
+// Availability returns the fraction of the desired replicas of a workload
+// which are available. A workload scaled to zero is fully available.
+func (s WorkloadStatus) Availability() float64 {
+	if s.DesiredReplicas <= 0 {
+		return 1
+	}
+
+	return float64(s.AvailableReplicas) / float64(s.DesiredReplicas)
+}
+
+// RequestHealth holds the recent request rates of a node, by protocol and
+// status code, e.g. {"http": {"200": 1.5, "503": 0.2}, "grpc": {"0": 1.2}}
+type RequestHealth struct {
+	Inbound           map[string]map[string]float64 `json:"inbound"`
+	Outbound          map[string]map[string]float64 `json:"outbound"`
+	HealthAnnotations map[string]string             `json:"healthAnnotations"`
+}
+
+// InboundErrorRatio returns the fraction of inbound requests which failed,
+// counting HTTP 5xx responses, requests that got no response at all and
+// non-OK gRPC statuses as failures.
+func (r RequestHealth) InboundErrorRatio() float64 {
+	return errorRatio(r.Inbound)
+}
+
+// OutboundErrorRatio returns the fraction of outbound requests which failed,
+// counted as for InboundErrorRatio.
+func (r RequestHealth) OutboundErrorRatio() float64 {
+	return errorRatio(r.Outbound)
+}
+
+func errorRatio(rates map[string]map[string]float64) float64 {
+	total, errors := 0.0, 0.0
+	for protocol, codes := range rates {
+		for code, rate := range codes {
+			total += rate
+			if isErrorCode(protocol, code) {
+				errors += rate
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return errors / total
+}
+
+func isErrorCode(protocol, code string) bool {
+	if code == "-" {
+		return true
+	}
+
+	if protocol == "grpc" {
+		return code != "0"
+	}
+
+	status, err := strconv.Atoi(code)
+	return err == nil && status >= 500
+}
+
+// WorkloadHealth is the health kiali computes for a workload
+type WorkloadHealth struct {
+	WorkloadStatus *WorkloadStatus `json:"workloadStatus"`
+	Requests       RequestHealth   `json:"requests"`
+}
+
+// AppHealth is the health kiali computes for an app, across the workloads
+// backing it
+type AppHealth struct {
+	WorkloadStatuses []*WorkloadStatus `json:"workloadStatuses"`
+	Requests         RequestHealth     `json:"requests"`
+}
+
+// ServiceHealth is the health kiali computes for a service
+type ServiceHealth struct {
+	Requests RequestHealth `json:"requests"`
+}
+
+// GetWorkloadHealth gives the health of every workload in a namespace, with
+// request rates taken over rateInterval, or kiali's default when 0
+func (kc *Client) GetWorkloadHealth(ctx context.Context, namespace string, rateInterval time.Duration) (map[string]*WorkloadHealth, error) {
+	health := make(map[string]*WorkloadHealth)
+	err := kc.getHealth(ctx, namespace, HealthTypeWorkload, rateInterval, &health)
+	return health, err
+}
+
+// GetAppHealth gives the health of every app in a namespace, with request
+// rates taken over rateInterval, or kiali's default when 0
+func (kc *Client) GetAppHealth(ctx context.Context, namespace string, rateInterval time.Duration) (map[string]*AppHealth, error) {
+	health := make(map[string]*AppHealth)
+	err := kc.getHealth(ctx, namespace, HealthTypeApp, rateInterval, &health)
+	return health, err
+}
+
+// GetServiceHealth gives the health of every service in a namespace, with
+// request rates taken over rateInterval, or kiali's default when 0
+func (kc *Client) GetServiceHealth(ctx context.Context, namespace string, rateInterval time.Duration) (map[string]*ServiceHealth, error) {
+	health := make(map[string]*ServiceHealth)
+	err := kc.getHealth(ctx, namespace, HealthTypeService, rateInterval, &health)
+	return health, err
+}
+
+// getHealth fetches the health of a kind for a namespace and decodes it into v
+func (kc *Client) getHealth(ctx context.Context, namespace, healthType string, rateInterval time.Duration, v interface{}) error {
+	if rateInterval < 0 || (rateInterval > 0 && rateInterval < time.Second) {
+		return fmt.Errorf("rate interval must be at least a second, got %v", rateInterval)
+	}
+
+	endpoint := fmt.Sprintf("kiali/api/namespaces/%s/health", namespace)
+
+	u := kc.url(endpoint)
+	q := u.Query()
+	q.Set("type", healthType)
+	if rateInterval > 0 {
+		q.Set("rateInterval", fmt.Sprintf("%ds", int64(rateInterval/time.Second)))
+	}
+	u.RawQuery = q.Encode()
+
+	body, err := kc.sendRequest(ctx, "GET", u.String())
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return newDecodeError(u.String(), err)
+	}
+
+	return nil
+}
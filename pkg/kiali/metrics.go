@@ -2,24 +2,331 @@ package kiali
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
+	"strconv"
+	"time"
 )
 
-// GetWorkloadMetrics gives the metrics for a specified workload in a namespace
-func (kc *Client) GetWorkloadMetrics(ctx context.Context, namespace, workload string) (string, error) {
+// Names of the metrics kiali serves for a workload
+const (
+	MetricRequestCount       = "request_count"
+	MetricRequestErrorCount  = "request_error_count"
+	MetricRequestDuration    = "request_duration_millis"
+	MetricRequestThroughput  = "request_throughput"
+	MetricResponseThroughput = "response_throughput"
+	MetricRequestSize        = "request_size"
+	MetricResponseSize       = "response_size"
+	MetricTCPReceived        = "tcp_received"
+	MetricTCPSent            = "tcp_sent"
+	MetricTCPOpened          = "tcp_opened"
+	MetricTCPClosed          = "tcp_closed"
+)
+
+// Directions of traffic metrics can be fetched for
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Proxies that can report metrics
+const (
+	ReporterSource      = "source"
+	ReporterDestination = "destination"
+)
+
+// StatAvg is the stat of histogram series holding the average
+const StatAvg = "avg"
+
+// MetricsQuery describes the metrics to fetch from kiali.
+//
+// Filters names the metrics to fetch, all of them when empty. Direction and
+// Reporter default to kiali's "outbound" and "source". The series cover the
+// Duration leading up to QueryTime, or up to now if QueryTime is zero, with a
+// point every Step and rates taken over RateInterval. Zero values are left for
+// kiali to default. Histograms (durations and sizes) have a series for every
+// quantile in Quantiles, and one for their average if Avg is set. ByLabels
+// splits every metric into a series per value of the given labels.
+type MetricsQuery struct {
+	Filters         []string
+	Direction       string
+	Reporter        string
+	RequestProtocol string
+	Duration        time.Duration
+	QueryTime       time.Time
+	Step            time.Duration
+	RateInterval    time.Duration
+	Quantiles       []float64
+	Avg             bool
+	ByLabels        []string
+}
+
+// Validate checks that a query only holds values kiali accepts.
+func (q MetricsQuery) Validate() error {
+	switch q.Direction {
+	case "", DirectionInbound, DirectionOutbound:
+	default:
+		return fmt.Errorf("invalid direction %q", q.Direction)
+	}
+
+	switch q.Reporter {
+	case "", ReporterSource, ReporterDestination:
+	default:
+		return fmt.Errorf("invalid reporter %q", q.Reporter)
+	}
+
+	if q.Duration < 0 || (q.Duration > 0 && q.Duration < time.Second) {
+		return fmt.Errorf("duration must be at least a second, got %v", q.Duration)
+	}
+	if q.Step < 0 || (q.Step > 0 && q.Step < time.Second) {
+		return fmt.Errorf("step must be at least a second, got %v", q.Step)
+	}
+	if q.RateInterval < 0 || (q.RateInterval > 0 && q.RateInterval < time.Second) {
+		return fmt.Errorf("rate interval must be at least a second, got %v", q.RateInterval)
+	}
+
+	for _, quantile := range q.Quantiles {
+		if quantile < 0 || quantile > 1 {
+			return fmt.Errorf("invalid quantile %v", quantile)
+		}
+	}
+
+	return nil
+}
+
+// Values validates a query and returns its kiali query parameters.
+func (q MetricsQuery) Values() (url.Values, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for _, filter := range q.Filters {
+		values.Add("filters[]", filter)
+	}
+	for _, quantile := range q.Quantiles {
+		values.Add("quantiles[]", strconv.FormatFloat(quantile, 'f', -1, 64))
+	}
+	for _, label := range q.ByLabels {
+		values.Add("byLabels[]", label)
+	}
+	values.Set("avg", strconv.FormatBool(q.Avg))
+
+	if q.Direction != "" {
+		values.Set("direction", q.Direction)
+	}
+	if q.Reporter != "" {
+		values.Set("reporter", q.Reporter)
+	}
+	if q.RequestProtocol != "" {
+		values.Set("requestProtocol", q.RequestProtocol)
+	}
+	if q.Duration > 0 {
+		values.Set("duration", strconv.FormatInt(int64(q.Duration/time.Second), 10))
+	}
+	if !q.QueryTime.IsZero() {
+		values.Set("queryTime", strconv.FormatInt(q.QueryTime.Unix(), 10))
+	}
+	if q.Step > 0 {
+		values.Set("step", strconv.FormatInt(int64(q.Step/time.Second), 10))
+	}
+	if q.RateInterval > 0 {
+		values.Set("rateInterval", fmt.Sprintf("%ds", int64(q.RateInterval/time.Second)))
+	}
+
+	return values, nil
+}
+
+// Datapoint is a single sample of a time series
+type Datapoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// UnmarshalJSON decodes a datapoint from kiali's [<unix seconds>, "<value>"]
+// form.
+func (d *Datapoint) UnmarshalJSON(bs []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("datapoint has %d fields, expected 2", len(raw))
+	}
+
+	var ts float64
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return err
+	}
+
+	var value string
+	if err := json.Unmarshal(raw[1], &value); err != nil {
+		return err
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+
+	sec, frac := math.Modf(ts)
+	d.Time = time.Unix(int64(sec), int64(math.Round(frac*1000))*int64(time.Millisecond))
+	d.Value = v
+	return nil
+}
+
+// TimeSeries is a metric over time. Stat is set on histogram series and is
+// either "avg" or a quantile such as "0.95".
+type TimeSeries struct {
+	Name       string            `json:"name"`
+	Stat       string            `json:"stat"`
+	Labels     map[string]string `json:"labels"`
+	Datapoints []Datapoint       `json:"datapoints"`
+}
+
+// Last returns the latest datapoint of a series. Points which are not a
+// number, as kiali sends for empty rates, are skipped.
+func (s TimeSeries) Last() (Datapoint, bool) {
+	for i := len(s.Datapoints) - 1; i >= 0; i-- {
+		if !math.IsNaN(s.Datapoints[i].Value) {
+			return s.Datapoints[i], true
+		}
+	}
+
+	return Datapoint{}, false
+}
+
+// Mean returns the mean value of a series, skipping points which are not a
+// number.
+func (s TimeSeries) Mean() (float64, bool) {
+	sum, n := 0.0, 0
+	for _, d := range s.Datapoints {
+		if math.IsNaN(d.Value) {
+			continue
+		}
+		sum += d.Value
+		n++
+	}
+
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// WorkloadMetrics holds the metrics of a workload. Request counts and
+// throughputs are rates per second, durations are in milliseconds, and sizes
+// and TCP throughputs are in bytes.
+type WorkloadMetrics struct {
+	RequestCount       []TimeSeries
+	RequestErrorCount  []TimeSeries
+	RequestDuration    []TimeSeries
+	RequestThroughput  []TimeSeries
+	ResponseThroughput []TimeSeries
+	RequestSize        []TimeSeries
+	ResponseSize       []TimeSeries
+	TCPReceived        []TimeSeries
+	TCPSent            []TimeSeries
+	TCPOpened          []TimeSeries
+	TCPClosed          []TimeSeries
+}
+
+// newWorkloadMetrics sorts the series of a kiali metrics response into
+// WorkloadMetrics
+func newWorkloadMetrics(metrics map[string][]TimeSeries) *WorkloadMetrics {
+	return &WorkloadMetrics{
+		RequestCount:       metrics[MetricRequestCount],
+		RequestErrorCount:  metrics[MetricRequestErrorCount],
+		RequestDuration:    metrics[MetricRequestDuration],
+		RequestThroughput:  metrics[MetricRequestThroughput],
+		ResponseThroughput: metrics[MetricResponseThroughput],
+		RequestSize:        metrics[MetricRequestSize],
+		ResponseSize:       metrics[MetricResponseSize],
+		TCPReceived:        metrics[MetricTCPReceived],
+		TCPSent:            metrics[MetricTCPSent],
+		TCPOpened:          metrics[MetricTCPOpened],
+		TCPClosed:          metrics[MetricTCPClosed],
+	}
+}
+
+// GetStat returns the series among a histogram's series with the given stat
+func GetStat(series []TimeSeries, stat string) (TimeSeries, bool) {
+	for _, s := range series {
+		if s.Stat == stat {
+			return s, true
+		}
+	}
+
+	return TimeSeries{}, false
+}
+
+// GetWorkloadMetrics gives the metrics described by a query for a specified
+// workload in a namespace
+func (kc *Client) GetWorkloadMetrics(ctx context.Context, namespace, workload string, query MetricsQuery) (*WorkloadMetrics, error) {
 	endpoint := fmt.Sprintf("kiali/api/namespaces/%s/workloads/%s/metrics", namespace, workload)
 
-	u := &url.URL{
-		Scheme: "http",
-		Host:   kc.host,
-		Path:   endpoint,
+	rawQuery, err := query.Values()
+	if err != nil {
+		return nil, err
 	}
 
+	u := kc.url(endpoint)
+	u.RawQuery = rawQuery.Encode()
+
 	body, err := kc.sendRequest(ctx, "GET", u.String())
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	metrics := make(map[string][]TimeSeries)
+	err = json.Unmarshal(body, &metrics)
+	if err != nil {
+		return nil, newDecodeError(u.String(), err)
+	}
+
+	return newWorkloadMetrics(metrics), nil
+}
+
+// QueueLength returns the queue length of a workload from its metrics, the
+// mean of its inbound response throughput times the mean of its average
+// request duration.
+func (m *WorkloadMetrics) QueueLength() float64 {
+	throughput := 0.0
+	for _, series := range m.ResponseThroughput {
+		if mean, ok := series.Mean(); ok {
+			throughput += mean
+		}
+	}
+
+	duration, ok := GetStat(m.RequestDuration, StatAvg)
+	if !ok {
+		return 0
+	}
+
+	responseTime, ok := duration.Mean()
+	if !ok {
+		return 0
+	}
+
+	return throughput * responseTime
+}
+
+// GetQueueLength gives the queue length of a workload in a namespace from its
+// inbound metrics over the Duration of query, sampled every Step with rates
+// over RateInterval, see WorkloadMetrics.QueueLength. The metrics fetched are
+// set by GetQueueLength.
+func (kc *Client) GetQueueLength(ctx context.Context, namespace, workload string, query MetricsQuery) (float64, error) {
+	query.Filters = []string{MetricResponseThroughput, MetricRequestDuration}
+	query.Direction = DirectionInbound
+	query.Reporter = ReporterDestination
+	query.Avg = true
+
+	metrics, err := kc.GetWorkloadMetrics(ctx, namespace, workload, query)
+	if err != nil {
+		return 0, err
 	}
 
-	return string(body), err
+	return metrics.QueueLength(), nil
 }
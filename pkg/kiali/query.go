@@ -0,0 +1,164 @@
+package kiali
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Graph types supported by kiali
+const (
+	GraphTypeWorkload     = "workload"
+	GraphTypeApp          = "app"
+	GraphTypeVersionedApp = "versionedApp"
+	GraphTypeService      = "service"
+)
+
+// Response time statistics the responseTime appender can report
+const (
+	ResponseTimeAvg = "avg"
+	ResponseTimeP50 = "50"
+	ResponseTimeP95 = "95"
+	ResponseTimeP99 = "99"
+)
+
+// Kinds of throughput the throughput appender can report
+const (
+	ThroughputRequest  = "request"
+	ThroughputResponse = "response"
+)
+
+// Ways nodes can be boxed in a graph
+const (
+	BoxByApp       = "app"
+	BoxByCluster   = "cluster"
+	BoxByNamespace = "namespace"
+)
+
+// GraphQuery describes a graph to fetch from kiali.
+//
+// The graph covers traffic in Namespaces over the Duration leading up to
+// QueryTime, or up to now if QueryTime is zero. GraphType defaults to a
+// workload graph. Appenders decorate the graph and besides the ones marking
+// service entry, idle and gateway nodes, only those asked for are run:
+// ResponseTime and Throughput name the statistic to report and are left out
+// when empty, Security and DeadNode are run when set.
+type GraphQuery struct {
+	GraphType          string
+	Namespaces         []string
+	Duration           time.Duration
+	QueryTime          time.Time
+	ResponseTime       string
+	Throughput         string
+	Security           bool
+	DeadNode           bool
+	InjectServiceNodes bool
+	BoxBy              []string
+}
+
+// Validate checks that a query only holds values kiali accepts.
+func (q GraphQuery) Validate() error {
+	switch q.GraphType {
+	case "", GraphTypeWorkload, GraphTypeApp, GraphTypeVersionedApp, GraphTypeService:
+	default:
+		return fmt.Errorf("invalid graph type %q", q.GraphType)
+	}
+
+	if len(q.Namespaces) == 0 {
+		return fmt.Errorf("at least one namespace is required")
+	}
+	for _, namespace := range q.Namespaces {
+		if namespace == "" || strings.Contains(namespace, ",") {
+			return fmt.Errorf("invalid namespace %q", namespace)
+		}
+	}
+
+	if q.Duration < time.Second {
+		return fmt.Errorf("duration must be at least a second, got %v", q.Duration)
+	}
+
+	switch q.ResponseTime {
+	case "", ResponseTimeAvg, ResponseTimeP50, ResponseTimeP95, ResponseTimeP99:
+	default:
+		return fmt.Errorf("invalid response time %q", q.ResponseTime)
+	}
+
+	switch q.Throughput {
+	case "", ThroughputRequest, ThroughputResponse:
+	default:
+		return fmt.Errorf("invalid throughput %q", q.Throughput)
+	}
+
+	for _, box := range q.BoxBy {
+		switch box {
+		case BoxByApp, BoxByCluster, BoxByNamespace:
+		default:
+			return fmt.Errorf("invalid box by %q", box)
+		}
+	}
+
+	return nil
+}
+
+// Values validates a query and returns its kiali query parameters.
+func (q GraphQuery) Values() (url.Values, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	graphType := q.GraphType
+	if graphType == "" {
+		graphType = GraphTypeWorkload
+	}
+
+	// Service entry, idle and gateway nodes are always marked, as graphs
+	// read them off every node
+	appenders := []string{"serviceEntry", "idleNode", "istio"}
+	if q.DeadNode {
+		appenders = append(appenders, "deadNode")
+	}
+	if q.ResponseTime != "" {
+		appenders = append(appenders, "responseTime")
+	}
+	if q.Security {
+		appenders = append(appenders, "securityPolicy")
+	}
+	if q.Throughput != "" {
+		appenders = append(appenders, "throughput")
+	}
+
+	values := url.Values{}
+	values.Set("graphType", graphType)
+	values.Set("namespaces", strings.Join(q.Namespaces, ","))
+	values.Set("duration", fmt.Sprintf("%ds", int64(q.Duration/time.Second)))
+	values.Set("appenders", strings.Join(appenders, ","))
+	values.Set("injectServiceNodes", strconv.FormatBool(q.InjectServiceNodes))
+
+	if !q.QueryTime.IsZero() {
+		values.Set("queryTime", strconv.FormatInt(q.QueryTime.Unix(), 10))
+	}
+	if q.ResponseTime != "" {
+		values.Set("responseTime", q.ResponseTime)
+	}
+	if q.Throughput != "" {
+		values.Set("throughputType", q.Throughput)
+	}
+	if len(q.BoxBy) > 0 {
+		values.Set("boxBy", strings.Join(q.BoxBy, ","))
+	}
+
+	return values, nil
+}
+
+// Encode validates a query and returns it as a kiali query string, with
+// parameters sorted by name.
+func (q GraphQuery) Encode() (string, error) {
+	values, err := q.Values()
+	if err != nil {
+		return "", err
+	}
+
+	return values.Encode(), nil
+}
@@ -0,0 +1,123 @@
+package kiali
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraphQueryValidate(t *testing.T) {
+	valid := GraphQuery{Namespaces: []string{"istio-teastore"}, Duration: time.Minute}
+
+	cases := []struct {
+		name   string
+		modify func(q *GraphQuery)
+		valid  bool
+	}{
+		{"defaults", func(q *GraphQuery) {}, true},
+		{"graph type", func(q *GraphQuery) { q.GraphType = GraphTypeVersionedApp }, true},
+		{"invalid graph type", func(q *GraphQuery) { q.GraphType = "pods" }, false},
+		{"no namespaces", func(q *GraphQuery) { q.Namespaces = nil }, false},
+		{"empty namespace", func(q *GraphQuery) { q.Namespaces = []string{"a", ""} }, false},
+		{"namespace with comma", func(q *GraphQuery) { q.Namespaces = []string{"a,b"} }, false},
+		{"no duration", func(q *GraphQuery) { q.Duration = 0 }, false},
+		{"sub second duration", func(q *GraphQuery) { q.Duration = 500 * time.Millisecond }, false},
+		{"response time", func(q *GraphQuery) { q.ResponseTime = ResponseTimeP95 }, true},
+		{"invalid response time", func(q *GraphQuery) { q.ResponseTime = "90" }, false},
+		{"throughput", func(q *GraphQuery) { q.Throughput = ThroughputResponse }, true},
+		{"invalid throughput", func(q *GraphQuery) { q.Throughput = "bytes" }, false},
+		{"box by", func(q *GraphQuery) { q.BoxBy = []string{BoxByApp, BoxByNamespace} }, true},
+		{"invalid box by", func(q *GraphQuery) { q.BoxBy = []string{"version"} }, false},
+	}
+
+	for _, c := range cases {
+		q := valid
+		c.modify(&q)
+
+		err := q.Validate()
+		if c.valid && err != nil {
+			t.Errorf("%s: expected no error, got %v", c.name, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestGraphQueryEncode(t *testing.T) {
+	queryTime := time.Unix(1634567890, 0)
+
+	cases := []struct {
+		name  string
+		query GraphQuery
+		want  string
+	}{
+		{
+			"defaults",
+			GraphQuery{
+				Namespaces: []string{"istio-teastore"},
+				Duration:   time.Minute,
+			},
+			"appenders=serviceEntry%2CidleNode%2Cistio" +
+				"&duration=60s" +
+				"&graphType=workload" +
+				"&injectServiceNodes=false" +
+				"&namespaces=istio-teastore",
+		},
+		{
+			"every option",
+			GraphQuery{
+				GraphType:          GraphTypeApp,
+				Namespaces:         []string{"istio-teastore", "istio-system"},
+				Duration:           90 * time.Second,
+				QueryTime:          queryTime,
+				ResponseTime:       ResponseTimeP99,
+				Throughput:         ThroughputRequest,
+				Security:           true,
+				DeadNode:           true,
+				InjectServiceNodes: true,
+				BoxBy:              []string{BoxByApp, BoxByCluster},
+			},
+			"appenders=serviceEntry%2CidleNode%2Cistio%2CdeadNode%2CresponseTime%2CsecurityPolicy%2Cthroughput" +
+				"&boxBy=app%2Ccluster" +
+				"&duration=90s" +
+				"&graphType=app" +
+				"&injectServiceNodes=true" +
+				"&namespaces=istio-teastore%2Cistio-system" +
+				"&queryTime=1634567890" +
+				"&responseTime=99" +
+				"&throughputType=request",
+		},
+		{
+			"durations truncated to seconds",
+			GraphQuery{
+				Namespaces:   []string{"istio-teastore"},
+				Duration:     1500 * time.Millisecond,
+				ResponseTime: ResponseTimeAvg,
+			},
+			"appenders=serviceEntry%2CidleNode%2Cistio%2CresponseTime" +
+				"&duration=1s" +
+				"&graphType=workload" +
+				"&injectServiceNodes=false" +
+				"&namespaces=istio-teastore" +
+				"&responseTime=avg",
+		},
+	}
+
+	for _, c := range cases {
+		got, err := c.query.Encode()
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGraphQueryEncodeInvalid(t *testing.T) {
+	q := GraphQuery{Namespaces: []string{"istio-teastore"}}
+	if _, err := q.Encode(); err == nil {
+		t.Fatal("expected an error encoding a query without a duration")
+	}
+}
@@ -0,0 +1,121 @@
+package kiali
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Defaults for sending requests to kiali
+const (
+	defaultMaxRetries      = 3
+	defaultBackoff         = 500 * time.Millisecond
+	defaultRequestTimeout  = 30 * time.Second
+	defaultMaxResponseSize = 32 << 20
+)
+
+// SetRetryPolicy sets how many times a request failing transiently is retried
+// and the backoff before the first retry, which doubles with every retry.
+func (kc *Client) SetRetryPolicy(maxRetries int, backoff time.Duration) {
+	kc.maxRetries = maxRetries
+	kc.backoff = backoff
+}
+
+// SetRequestTimeout sets the timeout of every single attempt of a request.
+func (kc *Client) SetRequestTimeout(timeout time.Duration) {
+	kc.requestTimeout = timeout
+}
+
+// SetMaxResponseSize sets the largest response body accepted, in bytes.
+func (kc *Client) SetMaxResponseSize(size int64) {
+	kc.maxResponseSize = size
+}
+
+// sendRequest constructs a request, sends it and returns the response body.
+// Transient failures are retried with exponential backoff, and an expired
+// login session is renewed once.
+func (kc *Client) sendRequest(ctx context.Context, method, url string) ([]byte, error) {
+	if err := kc.ensureLogin(ctx, false); err != nil {
+		return nil, err
+	}
+
+	backoff := kc.backoff
+	renewedLogin := false
+
+	for attempt := 0; ; attempt++ {
+		body, err := kc.attempt(ctx, method, url)
+		if err == nil {
+			return body, nil
+		}
+
+		// Sessions expire, log in again once and retry
+		if errors.Is(err, ErrUnauthorized) && kc.auth.Strategy == StrategyLogin && !renewedLogin {
+			renewedLogin = true
+			if err := kc.ensureLogin(ctx, true); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		if ctx.Err() != nil || attempt >= kc.maxRetries || !isTransient(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// attempt sends a request once, bounded by the request timeout, and reads at
+// most the maximum response size of its body.
+func (kc *Client) attempt(ctx context.Context, method, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, kc.requestTimeout)
+	defer cancel()
+
+	resp, err := kc.do(ctx, method, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, kc.maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newStatusError(url, resp.StatusCode, body)
+	}
+
+	if int64(len(body)) > kc.maxResponseSize {
+		return nil, &RequestError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Err:        ErrResponseTooLarge,
+		}
+	}
+
+	return body, nil
+}
+
+// do sends a single authorized request
+func (kc *Client) do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := kc.authorize(req); err != nil {
+		return nil, err
+	}
+
+	return kc.httpClient.Do(req)
+}
@@ -0,0 +1,36 @@
+package kiali
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// Snapshot is a graph as kiali served it at some point in time
+type Snapshot struct {
+	Time  time.Time `json:"time"`
+	Graph Graph     `json:"graph"`
+}
+
+// SaveSnapshot writes a snapshot to a file as JSON
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	bs, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, bs, 0644)
+}
+
+// LoadSnapshot reads a snapshot saved by SaveSnapshot
+func LoadSnapshot(path string) (Snapshot, error) {
+	snapshot := Snapshot{}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(bs, &snapshot)
+	return snapshot, err
+}
@@ -3,6 +3,10 @@ package kiali
 import (
 	"fmt"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
 
 	graph "github.com/kiali/kiali/graph/config/cytoscape"
 )
@@ -10,28 +14,101 @@ import (
 // Client is a type to help interact with kiali dashboards
 type Client struct {
 	httpClient *http.Client
+	scheme     string
 	host       string
+	auth       Auth
+
+	maxRetries      int
+	backoff         time.Duration
+	requestTimeout  time.Duration
+	maxResponseSize int64
+
+	loginMu  sync.Mutex
+	loggedIn bool
 }
 
 // Graph is a type that holds nodes and its edges indexed by node ID
 type Graph map[string]*Item
 
-// Item is a graph element
+// Node types found in kiali graphs
+const (
+	NodeTypeApp      = "app"
+	NodeTypeService  = "service"
+	NodeTypeWorkload = "workload"
+	NodeTypeUnknown  = "unknown"
+)
+
+// Item is a graph element. Type is the node type, one of "app", "service",
+// "workload" or "unknown" for traffic from outside the mesh. IsRoot is set for
+// nodes traffic starts from, IsOutside for nodes outside the namespaces of the
+// graph, IsServiceEntry for services external to the mesh, IsIngressGateway
+// for Istio ingress gateways and IsIdle for nodes which saw no traffic.
+// FanOuts holds, for sources that know it, how many calls every request into
+// the node leads to along its edges, by target node ID.
 type Item struct {
-	Node  *graph.NodeData   `json:"node"`
-	Edges []*graph.EdgeData `json:"edges"`
+	Node             *graph.NodeData    `json:"node"`
+	Edges            []*graph.EdgeData  `json:"edges"`
+	Type             string             `json:"type"`
+	IsRoot           bool               `json:"isRoot"`
+	IsOutside        bool               `json:"isOutside"`
+	IsServiceEntry   bool               `json:"isServiceEntry"`
+	IsIngressGateway bool               `json:"isIngressGateway"`
+	IsIdle           bool               `json:"isIdle"`
+	FanOuts          map[string]float64 `json:"fanOuts,omitempty"`
 }
 
-// NewKialiClient is a constructor for type KialiClient
-func NewKialiClient(host string, port int, hc *http.Client) *Client {
+// NewKialiClient is a constructor for type KialiClient. The scheme defaults to
+// "http" when empty. If hc is nil, an http client is built with the TLS
+// settings of auth, otherwise hc is used as is for TLS.
+func NewKialiClient(scheme, host string, port int, hc *http.Client, auth Auth) (*Client, error) {
+	auth, err := resolveAuth(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme == "" {
+		scheme = "http"
+	}
+
 	kc := Client{
-		host: fmt.Sprintf("%s:%d", host, port),
+		scheme:          scheme,
+		host:            fmt.Sprintf("%s:%d", host, port),
+		auth:            auth,
+		maxRetries:      defaultMaxRetries,
+		backoff:         defaultBackoff,
+		requestTimeout:  defaultRequestTimeout,
+		maxResponseSize: defaultMaxResponseSize,
 	}
 
 	if hc != nil {
 		kc.httpClient = hc
 	} else {
-		kc.httpClient = http.DefaultClient
+		kc.httpClient, err = newHTTPClient(auth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Login sessions need somewhere to keep their cookie
+	if auth.Strategy == StrategyLogin && kc.httpClient.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		withJar := *kc.httpClient
+		withJar.Jar = jar
+		kc.httpClient = &withJar
+	}
+
+	return &kc, nil
+}
+
+// url returns the URL of an endpoint of kiali
+func (kc *Client) url(endpoint string) *url.URL {
+	return &url.URL{
+		Scheme: kc.scheme,
+		Host:   kc.host,
+		Path:   endpoint,
 	}
-	return &kc
 }
@@ -0,0 +1,341 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/prometheus"
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+	"golang.org/x/sync/errgroup"
+)
+
+// Linkerd proxy metrics the graph is built from. Responses are the bytes an
+// outbound proxy reads from the destination and an inbound proxy writes back
+// to its caller.
+const (
+	metricResponses    = "response_total"
+	metricLatencySum   = "response_latency_ms_sum"
+	metricLatencyCount = "response_latency_ms_count"
+	metricReadBytes    = "tcp_read_bytes_total"
+	metricWriteBytes   = "tcp_write_bytes_total"
+)
+
+// unknownWorkload is the node standing for traffic from outside the mesh
+const unknownWorkload = "unknown"
+
+// minUnmeshedShare is the share of a deployment's inbound requests below which
+// requests not accounted for by meshed callers are put down to the skew
+// between inbound and outbound samples rather than to callers outside
+const minUnmeshedShare = 0.01
+
+var (
+	edgeLabels    = []string{"namespace", "deployment", "dst_namespace", "dst_deployment"}
+	inboundLabels = []string{"namespace", "deployment"}
+)
+
+// Client builds graphs from the metrics the Linkerd proxies report to the
+// prometheus Linkerd scrapes
+type Client struct {
+	prom *prometheus.Client
+}
+
+// NewLinkerdClient is a constructor for type Client, querying the prometheus
+// Linkerd scrapes through pc
+func NewLinkerdClient(pc *prometheus.Client) *Client {
+	return &Client{prom: pc}
+}
+
+// edgeTraffic accumulates what the samples of an edge add up to
+type edgeTraffic struct {
+	rate          float64
+	failureRate   float64
+	latencySum    float64
+	latencyCount  float64
+	responseBytes float64
+}
+
+func (e *edgeTraffic) add(sample prometheus.Sample, metric string) {
+	v := sample.Value.Value
+	switch metric {
+	case metricResponses:
+		e.rate += v
+		if sample.Metric["classification"] == "failure" {
+			e.failureRate += v
+		}
+	case metricLatencySum:
+		e.latencySum += v
+	case metricLatencyCount:
+		e.latencyCount += v
+	case metricReadBytes, metricWriteBytes:
+		e.responseBytes += v
+	}
+}
+
+// GetGraph builds the deployment graph described by a query from the metrics
+// of the Linkerd proxies, in the same shape as the graphs kiali serves.
+// Outbound proxy metrics give the edges between meshed deployments, with their
+// request rate, percentage of failed requests, average latency and response
+// throughput in bytes per second, as in the graphs of kiali. Requests and
+// response bytes of a deployment that its inbound proxy saw but no meshed
+// caller accounts for come from the "unknown" node.
+func (lc *Client) GetGraph(ctx context.Context, query prometheus.GraphQuery) (kiali.Graph, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		metric  string
+		inbound bool
+		vector  prometheus.Vector
+	}
+
+	results := []*result{
+		{metric: metricResponses},
+		{metric: metricLatencySum},
+		{metric: metricLatencyCount},
+		{metric: metricReadBytes},
+		{metric: metricResponses, inbound: true},
+		{metric: metricLatencySum, inbound: true},
+		{metric: metricLatencyCount, inbound: true},
+		{metric: metricWriteBytes, inbound: true},
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, r := range results {
+		r := r
+		eg.Go(func() error {
+			promQL := outboundQuery(query, r.metric)
+			if r.inbound {
+				promQL = inboundQuery(query, r.metric)
+			}
+
+			vector, err := lc.prom.Query(egCtx, promQL, query.QueryTime)
+			r.vector = vector
+			return err
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	edges := make(map[[2]string]*edgeTraffic)
+	inbound := make(map[string]*edgeTraffic)
+	namespaces := make(map[string]string)
+
+	for _, r := range results {
+		for _, sample := range r.vector {
+			target := sample.Metric["deployment"]
+			targetNamespace := sample.Metric["namespace"]
+			if !r.inbound {
+				target = sample.Metric["dst_deployment"]
+				targetNamespace = sample.Metric["dst_namespace"]
+			}
+			if target == "" {
+				continue
+			}
+			targetID := targetNamespace + "/" + target
+			namespaces[targetID] = targetNamespace
+
+			if r.inbound {
+				if _, ok := inbound[targetID]; !ok {
+					inbound[targetID] = &edgeTraffic{}
+				}
+				inbound[targetID].add(sample, r.metric)
+				continue
+			}
+
+			sourceID := sample.Metric["namespace"] + "/" + sample.Metric["deployment"]
+			namespaces[sourceID] = sample.Metric["namespace"]
+
+			key := [2]string{sourceID, targetID}
+			if _, ok := edges[key]; !ok {
+				edges[key] = &edgeTraffic{}
+			}
+			edges[key].add(sample, r.metric)
+		}
+	}
+
+	// Whatever inbound traffic meshed callers do not account for came from
+	// outside the mesh
+	for targetID, in := range inbound {
+		meshed := &edgeTraffic{}
+		for key, edge := range edges {
+			if key[1] == targetID {
+				meshed.rate += edge.rate
+				meshed.failureRate += edge.failureRate
+				meshed.responseBytes += edge.responseBytes
+			}
+		}
+
+		unmeshed := in.rate - meshed.rate
+		if in.rate <= 0 || unmeshed/in.rate < minUnmeshedShare {
+			continue
+		}
+
+		failures := in.failureRate - meshed.failureRate
+		if failures < 0 {
+			failures = 0
+		}
+		responseBytes := in.responseBytes - meshed.responseBytes
+		if responseBytes < 0 {
+			responseBytes = 0
+		}
+		edges[[2]string{unknownWorkload, targetID}] = &edgeTraffic{
+			rate:          unmeshed,
+			failureRate:   failures,
+			latencySum:    in.latencySum,
+			latencyCount:  in.latencyCount,
+			responseBytes: responseBytes,
+		}
+	}
+
+	g, _ := kiali.MakeGraph(config(query, edges, namespaces))
+	return g, nil
+}
+
+// outboundQuery returns a query for the per second rate of a proxy metric on
+// the outbound side, by edge. It covers the requests from deployments in the
+// namespaces of the graph and the requests into them from other namespaces.
+// Bytes are those read from the destination.
+func outboundQuery(query prometheus.GraphQuery, metric string) string {
+	pattern := strings.Join(query.Namespaces, "|")
+	window := fmt.Sprintf("%ds", int64(query.Duration/time.Second))
+
+	by := edgeLabels
+	if metric == metricResponses {
+		by = append(append([]string{}, edgeLabels...), "classification")
+	}
+	labels := strings.Join(by, ",")
+
+	peer := ""
+	if metric == metricReadBytes {
+		peer = `,peer="dst"`
+	}
+
+	from := fmt.Sprintf(`direction="outbound",namespace=~"%s",dst_deployment!=""%s`, pattern, peer)
+	into := fmt.Sprintf(`direction="outbound",namespace!~"%s",dst_namespace=~"%s",dst_deployment!=""%s`, pattern, pattern, peer)
+
+	return fmt.Sprintf(
+		"sum(rate(%s{%s}[%s])) by (%s) or sum(rate(%s{%s}[%s])) by (%s)",
+		metric, from, window, labels,
+		metric, into, window, labels,
+	)
+}
+
+// inboundQuery returns a query for the per second rate of a proxy metric on
+// the inbound side of every deployment in the namespaces of the graph. Bytes
+// are those written back to the caller.
+func inboundQuery(query prometheus.GraphQuery, metric string) string {
+	pattern := strings.Join(query.Namespaces, "|")
+	window := fmt.Sprintf("%ds", int64(query.Duration/time.Second))
+
+	by := inboundLabels
+	if metric == metricResponses {
+		by = append(append([]string{}, inboundLabels...), "classification")
+	}
+
+	peer := ""
+	if metric == metricWriteBytes {
+		peer = `,peer="src"`
+	}
+
+	return fmt.Sprintf(
+		`sum(rate(%s{direction="inbound",namespace=~"%s"%s}[%s])) by (%s)`,
+		metric, pattern, peer, window, strings.Join(by, ","),
+	)
+}
+
+// config returns the edges found as a kiali graph config. Nodes no edge leads
+// into are marked as roots, and nodes outside the namespaces of the query as
+// outside.
+func config(query prometheus.GraphQuery, edges map[[2]string]*edgeTraffic, namespaces map[string]string) *graph.Config {
+	inQuery := make(map[string]bool)
+	for _, namespace := range query.Namespaces {
+		inQuery[namespace] = true
+	}
+
+	keys := make([][2]string, 0, len(edges))
+	hasInbound := make(map[string]bool)
+	for key := range edges {
+		keys = append(keys, key)
+		hasInbound[key[1]] = true
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	conf := &graph.Config{
+		Timestamp: time.Now().Unix(),
+		Duration:  int64(query.Duration / time.Second),
+		GraphType: kiali.GraphTypeWorkload,
+	}
+	if !query.QueryTime.IsZero() {
+		conf.Timestamp = query.QueryTime.Unix()
+	}
+
+	added := make(map[string]bool)
+	addNode := func(id string) {
+		if added[id] {
+			return
+		}
+		added[id] = true
+
+		node := &graph.NodeData{
+			ID:       id,
+			NodeType: kiali.NodeTypeUnknown,
+			IsRoot:   !hasInbound[id],
+		}
+		if id == unknownWorkload {
+			node.Namespace = unknownWorkload
+			node.Workload = unknownWorkload
+		} else {
+			node.NodeType = kiali.NodeTypeWorkload
+			node.Namespace = namespaces[id]
+			node.Workload = strings.TrimPrefix(id, namespaces[id]+"/")
+			node.IsOutside = !inQuery[namespaces[id]]
+		}
+		conf.Elements.Nodes = append(conf.Elements.Nodes, &graph.NodeWrapper{Data: node})
+	}
+
+	for i, key := range keys {
+		addNode(key[0])
+		addNode(key[1])
+
+		edge := edges[key]
+		rates := map[string]string{"http": formatFloat(edge.rate, 2)}
+		if edge.rate > 0 {
+			rates["httpPercentErr"] = formatFloat(100*edge.failureRate/edge.rate, 1)
+		}
+
+		data := &graph.EdgeData{
+			ID:         fmt.Sprintf("e%d", i),
+			Source:     key[0],
+			Target:     key[1],
+			Throughput: formatFloat(edge.responseBytes, 0),
+			Traffic: graph.ProtocolTraffic{
+				Protocol: "http",
+				Rates:    rates,
+			},
+		}
+		if edge.latencyCount > 0 {
+			data.ResponseTime = formatFloat(edge.latencySum/edge.latencyCount, 2)
+		}
+
+		conf.Elements.Edges = append(conf.Elements.Edges, &graph.EdgeWrapper{Data: data})
+	}
+
+	return conf
+}
+
+func formatFloat(f float64, prec int) string {
+	return strconv.FormatFloat(f, 'f', prec, 64)
+}
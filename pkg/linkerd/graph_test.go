@@ -0,0 +1,227 @@
+package linkerd
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/prometheus"
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// newFakePrometheus starts a server answering the queries of a graph with
+// the recorded vectors in testdata, named after the side of the proxies and
+// the metric a query is on, e.g. inbound_response_total.json
+func newFakePrometheus(t *testing.T) *prometheus.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+
+		direction := "outbound"
+		if strings.Contains(query, `direction="inbound"`) {
+			direction = "inbound"
+		}
+
+		for _, metric := range []string{metricResponses, metricLatencySum, metricLatencyCount, metricReadBytes, metricWriteBytes} {
+			if !strings.Contains(query, metric+"{") {
+				continue
+			}
+
+			body, err := ioutil.ReadFile(filepath.Join("testdata", direction+"_"+metric+".json"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(body)
+			return
+		}
+
+		http.Error(w, "unexpected query "+query, http.StatusBadRequest)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return prometheus.NewPrometheusClient("http", host, port, nil)
+}
+
+// onlyEdge returns the single edge to target among edges
+func onlyEdge(t *testing.T, edges []*graph.EdgeData, target string) *graph.EdgeData {
+	t.Helper()
+
+	var found *graph.EdgeData
+	for _, edge := range edges {
+		if edge.Target == target {
+			if found != nil {
+				t.Fatalf("expected a single edge to %s", target)
+			}
+			found = edge
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected an edge to %s", target)
+	}
+
+	return found
+}
+
+func TestGetGraph(t *testing.T) {
+	lc := NewLinkerdClient(newFakePrometheus(t))
+
+	g, err := lc.GetGraph(context.Background(), prometheus.GraphQuery{Namespaces: []string{"shop"}, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"unknown", "shop/web", "shop/api", "shop/db", "other/cron", "pay/charge"}
+	if len(g) != len(want) {
+		t.Fatalf("expected nodes %v, got %d nodes", want, len(g))
+	}
+	for _, id := range want {
+		if _, ok := g[id]; !ok {
+			t.Fatalf("expected a node %s", id)
+		}
+	}
+
+	for id, item := range g {
+		if wantRoot := id == unknownWorkload || id == "other/cron"; item.IsRoot != wantRoot {
+			t.Errorf("%s: expected root to be %v", id, wantRoot)
+		}
+		if wantOutside := id == "other/cron" || id == "pay/charge"; item.IsOutside != wantOutside {
+			t.Errorf("%s: expected outside to be %v", id, wantOutside)
+		}
+	}
+
+	edge := onlyEdge(t, g["shop/web"].Edges, "shop/api")
+	if rates := edge.Traffic.Rates; rates["http"] != "100.00" || rates["httpPercentErr"] != "10.0" {
+		t.Errorf("unexpected rates from web to api: %v", rates)
+	}
+	if edge.ResponseTime != "20.00" || edge.Throughput != "204800" {
+		t.Errorf("expected 20ms and 204800 response bytes from web to api, got %q and %q", edge.ResponseTime, edge.Throughput)
+	}
+
+	edge = onlyEdge(t, g["shop/api"].Edges, "shop/db")
+	if rates := edge.Traffic.Rates; rates["http"] != "50.00" || rates["httpPercentErr"] != "0.0" || edge.ResponseTime != "5.00" || edge.Throughput != "25600" {
+		t.Errorf("unexpected traffic from api to db: %v, %q, %q", rates, edge.ResponseTime, edge.Throughput)
+	}
+
+	// The latency sum of the edge is not a number, and is left out
+	edge = onlyEdge(t, g["shop/api"].Edges, "pay/charge")
+	if edge.Traffic.Rates["http"] != "4.00" || edge.ResponseTime != "" {
+		t.Errorf("unexpected traffic from api to charge: %v, %q", edge.Traffic.Rates, edge.ResponseTime)
+	}
+
+	edge = onlyEdge(t, g["other/cron"].Edges, "shop/api")
+	if edge.Traffic.Rates["http"] != "5.00" || edge.ResponseTime != "10.00" {
+		t.Errorf("unexpected traffic from cron to api: %v, %q", edge.Traffic.Rates, edge.ResponseTime)
+	}
+}
+
+func TestGetGraphUnmeshed(t *testing.T) {
+	lc := NewLinkerdClient(newFakePrometheus(t))
+
+	g, err := lc.GetGraph(context.Background(), prometheus.GraphQuery{Namespaces: []string{"shop"}, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := g[unknownWorkload].Edges
+	if len(unknown) != 2 {
+		t.Fatalf("expected unmeshed edges into web and api only, got %d", len(unknown))
+	}
+
+	// No meshed caller accounts for any request into web, so they all come
+	// from outside, failures and latency included
+	edge := onlyEdge(t, unknown, "shop/web")
+	if rates := edge.Traffic.Rates; rates["http"] != "22.00" || rates["httpPercentErr"] != "9.1" {
+		t.Errorf("unexpected rates from outside into web: %v", rates)
+	}
+	if edge.ResponseTime != "30.00" || edge.Throughput != "45056" {
+		t.Errorf("expected the inbound latency and response bytes of web, got %q and %q", edge.ResponseTime, edge.Throughput)
+	}
+
+	// Meshed callers account for 105 of the 110.5 requests into api, for all
+	// of its failures and for 209920 of the 226304 bytes it wrote back
+	edge = onlyEdge(t, unknown, "shop/api")
+	if rates := edge.Traffic.Rates; rates["http"] != "5.50" || rates["httpPercentErr"] != "0.0" {
+		t.Errorf("unexpected rates from outside into api: %v", rates)
+	}
+	if edge.ResponseTime != "10.00" || edge.Throughput != "16384" {
+		t.Errorf("expected the inbound latency of api and the bytes left over, got %q and %q", edge.ResponseTime, edge.Throughput)
+	}
+
+	// The 0.2 requests into db no meshed caller accounts for are under the
+	// cutoff, and put down to skew between inbound and outbound samples
+	for _, edge := range unknown {
+		if edge.Target == "shop/db" {
+			t.Fatalf("expected no unmeshed edge into db below a share of %v", minUnmeshedShare)
+		}
+	}
+}
+
+func TestOutboundQuery(t *testing.T) {
+	query := prometheus.GraphQuery{Namespaces: []string{"shop", "pay"}, Duration: time.Minute}
+
+	got := outboundQuery(query, metricResponses)
+	want := `sum(rate(response_total{direction="outbound",namespace=~"shop|pay",dst_deployment!=""}[60s])) by (namespace,deployment,dst_namespace,dst_deployment,classification)` +
+		` or sum(rate(response_total{direction="outbound",namespace!~"shop|pay",dst_namespace=~"shop|pay",dst_deployment!=""}[60s])) by (namespace,deployment,dst_namespace,dst_deployment,classification)`
+	if got != want {
+		t.Errorf("unexpected outbound responses query:\n got %s\nwant %s", got, want)
+	}
+
+	got = outboundQuery(query, metricLatencySum)
+	want = `sum(rate(response_latency_ms_sum{direction="outbound",namespace=~"shop|pay",dst_deployment!=""}[60s])) by (namespace,deployment,dst_namespace,dst_deployment)` +
+		` or sum(rate(response_latency_ms_sum{direction="outbound",namespace!~"shop|pay",dst_namespace=~"shop|pay",dst_deployment!=""}[60s])) by (namespace,deployment,dst_namespace,dst_deployment)`
+	if got != want {
+		t.Errorf("unexpected outbound latency query:\n got %s\nwant %s", got, want)
+	}
+
+	got = outboundQuery(query, metricReadBytes)
+	want = `sum(rate(tcp_read_bytes_total{direction="outbound",namespace=~"shop|pay",dst_deployment!="",peer="dst"}[60s])) by (namespace,deployment,dst_namespace,dst_deployment)` +
+		` or sum(rate(tcp_read_bytes_total{direction="outbound",namespace!~"shop|pay",dst_namespace=~"shop|pay",dst_deployment!="",peer="dst"}[60s])) by (namespace,deployment,dst_namespace,dst_deployment)`
+	if got != want {
+		t.Errorf("unexpected outbound bytes query:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestInboundQuery(t *testing.T) {
+	query := prometheus.GraphQuery{Namespaces: []string{"shop"}, Duration: 5 * time.Minute}
+
+	got := inboundQuery(query, metricResponses)
+	want := `sum(rate(response_total{direction="inbound",namespace=~"shop"}[300s])) by (namespace,deployment,classification)`
+	if got != want {
+		t.Errorf("unexpected inbound responses query:\n got %s\nwant %s", got, want)
+	}
+
+	got = inboundQuery(query, metricLatencyCount)
+	want = `sum(rate(response_latency_ms_count{direction="inbound",namespace=~"shop"}[300s])) by (namespace,deployment)`
+	if got != want {
+		t.Errorf("unexpected inbound latency query:\n got %s\nwant %s", got, want)
+	}
+
+	got = inboundQuery(query, metricWriteBytes)
+	want = `sum(rate(tcp_write_bytes_total{direction="inbound",namespace=~"shop",peer="src"}[300s])) by (namespace,deployment)`
+	if got != want {
+		t.Errorf("unexpected inbound bytes query:\n got %s\nwant %s", got, want)
+	}
+}
@@ -41,6 +41,22 @@ func (sc *StressClient) getDistribution(distributionType string, steps int, minR
 	return result
 }
 
+// getSchedule returns the steps of a distribution from index from onwards,
+// with the first of them starting at start.
+func getSchedule(distribution []int, from int, start time.Time, stepDuration time.Duration) []Step {
+	steps := make([]Step, 0, len(distribution)-from)
+	for i := from; i < len(distribution); i++ {
+		steps = append(steps, Step{
+			Index:    i,
+			Start:    start.Add(time.Duration(i-from) * stepDuration),
+			Rate:     distribution[i],
+			Duration: stepDuration,
+		})
+	}
+
+	return steps
+}
+
 // StressApplication stress tests the application using a given number of
 // workers, for a specified duration, with minimum and maximum rate of requests
 // sent for iterations specified by steps.
@@ -55,13 +71,19 @@ func (sc *StressClient) StressApplication(conf config.LoadParameters) {
 
 	fmt.Println(conf, distribution)
 
-	for _, frequency := range distribution {
+	stepDuration := time.Duration(conf.Duration) * time.Second
+
+	for i, frequency := range distribution {
+		if sc.onSchedule != nil {
+			sc.onSchedule(getSchedule(distribution, i, time.Now(), stepDuration))
+		}
+
 		fmt.Println("Frequency: ", frequency)
 		rate := vegeta.Rate{Freq: frequency, Per: time.Second}
 		attackerFunc := vegeta.Workers(uint64(conf.Workers))
 		attacker := vegeta.NewAttacker(attackerFunc, vegeta.KeepAlive(false), vegeta.Connections(20000))
 		targeter := vegeta.NewStaticTargeter(targets...)
-		res := attacker.Attack(targeter, rate, time.Duration(conf.Duration)*time.Second, "")
+		res := attacker.Attack(targeter, rate, stepDuration, "")
 		open := true
 		// var result *vegeta.Result
 
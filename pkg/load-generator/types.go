@@ -3,6 +3,7 @@ package load
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	vegeta "github.com/tsenart/vegeta/lib"
 )
@@ -13,6 +14,17 @@ type StressClient struct {
 	httpClient *http.Client
 	host       string
 	getTargets func() []vegeta.Target
+	onSchedule func([]Step)
+}
+
+// Step is a planned step of load, sending Rate requests per second for
+// Duration from Start onwards. Index is the position of the step in the whole
+// schedule.
+type Step struct {
+	Index    int
+	Start    time.Time
+	Rate     int
+	Duration time.Duration
 }
 
 // NewStressClient is a constructor for StressClient
@@ -35,3 +47,9 @@ func NewStressClient(scheme, host string, port int, hc *http.Client) StressClien
 func (lc *StressClient) SetTargetFunction(targetFunc func() []vegeta.Target) {
 	lc.getTargets = targetFunc
 }
+
+// SetScheduleFunction is used to set a function which is handed the remaining
+// schedule of load steps every time a step begins.
+func (lc *StressClient) SetScheduleFunction(scheduleFunc func([]Step)) {
+	lc.onSchedule = scheduleFunc
+}
@@ -3,14 +3,19 @@ package metricscraper
 import (
 	"context"
 	"path/filepath"
-	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
 
 	// Import Auth for provider specific auth
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -26,7 +31,9 @@ import (
 // that any and all operations that make use of these metrics
 // are long running operations.
 type Client struct {
-	client *metricsv.Clientset
+	client   metricsv.Interface
+	custom   custom_metrics.CustomMetricsClient
+	external external_metrics.ExternalMetricsClient
 }
 
 // NewMetricClient inits a new clientset from a local kubeconfig and slaps a MetricClient around it.
@@ -44,66 +51,34 @@ func NewMetricClient() (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{clientset}, err
-}
-
-// StreamAllPodMetrics returns a channel that has a []v1beta1.PodMetrics put in it every freq Duration.
-func (c *Client) StreamAllPodMetrics(ctx context.Context, namespace string, freq time.Duration) <-chan []v1beta1.PodMetrics {
-	metricChan := make(chan []v1beta1.PodMetrics, 1)
-	go func() {
-		ticker := time.NewTicker(freq)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return // prevent goroutine leak.
-			case <-ticker.C:
-				metrics, err := c.GetAllPodMetrics(ctx, namespace)
-				// hack. just log error, don't really do anything about it for now.
-				if err != nil {
-					// log.Println(err)
-				}
-				metricChan <- metrics
-			}
-		}
-	}()
-
-	return metricChan
-}
-
-// TODO: stream pod metric, map deployment to metric type.
-
-// StreamPodMetrics returns a channel that has *v1beta1.PodMetrics put in it every freq Duration.
-func (c *Client) StreamPodMetrics(ctx context.Context, namespace, name string, freq time.Duration) <-chan *v1beta1.PodMetrics {
-	metricChan := make(chan *v1beta1.PodMetrics, 1)
-	go func() {
-		ticker := time.NewTicker(freq)
-		defer ticker.Stop()
+	// The custom metrics client finds the API version the adapter serves and
+	// the resources metrics describe through discovery
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	custom := custom_metrics.NewForConfig(config, mapper, custom_metrics.NewAvailableAPIsGetter(discoveryClient))
 
-		for {
-			select {
-			case <-ctx.Done():
-				return // prevent goroutine leak.
-			case <-ticker.C:
-				metrics, err := c.GetPodMetrics(ctx, namespace, name)
-				// hack. just log error, don't really do anything about it for now.
-				if err != nil {
-					// log.Println(err)
-				}
-				metricChan <- metrics
-			}
-		}
-	}()
+	external, err := external_metrics.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
-	return metricChan
+	return &Client{client: clientset, custom: custom, external: external}, err
 }
 
 // GetAllPodMetrics returns metrics of all pods in a namespace.
 func (c *Client) GetAllPodMetrics(ctx context.Context, namespace string) ([]v1beta1.PodMetrics, error) {
+	return c.ListPodMetrics(ctx, namespace, nil)
+}
+
+// ListPodMetrics returns metrics of the pods in a namespace whose labels match
+// a selector, in a single call. A nil selector matches every pod.
+func (c *Client) ListPodMetrics(ctx context.Context, namespace string, selector labels.Selector) ([]v1beta1.PodMetrics, error) {
 	podMetrices, err := c.client.MetricsV1beta1().
 		PodMetricses(namespace).
-		List(ctx, metav1.ListOptions{})
+		List(ctx, metav1.ListOptions{LabelSelector: orEverything(selector).String()})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,65 @@
+package metricscraper
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	cmv1beta2 "k8s.io/metrics/pkg/apis/custom_metrics/v1beta2"
+	emv1beta1 "k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+)
+
+// DeploymentKind is the group kind of deployments, whose own metrics are read
+// with GetObjectCustomMetric
+var DeploymentKind = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+var podKind = schema.GroupKind{Kind: "Pod"}
+
+// GetPodsCustomMetric gets a metric of every pod in a namespace matched by a
+// selector from custom.metrics.k8s.io. A nil metricSelector matches every
+// series of the metric.
+func (c *Client) GetPodsCustomMetric(ctx context.Context, namespace string, selector labels.Selector, metric string, metricSelector labels.Selector) ([]cmv1beta2.MetricValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := c.custom.NamespacedMetrics(namespace).GetForObjects(podKind, selector, metric, orEverything(metricSelector))
+	if err != nil {
+		return nil, err
+	}
+
+	return values.Items, nil
+}
+
+// GetObjectCustomMetric gets a metric describing a named object in a namespace,
+// e.g. a deployment, from custom.metrics.k8s.io
+func (c *Client) GetObjectCustomMetric(ctx context.Context, namespace string, groupKind schema.GroupKind, name, metric string, metricSelector labels.Selector) (*cmv1beta2.MetricValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.custom.NamespacedMetrics(namespace).GetForObject(groupKind, name, metric, orEverything(metricSelector))
+}
+
+// GetExternalMetric gets every series of a metric from outside the cluster
+// matched by metricSelector from external.metrics.k8s.io
+func (c *Client) GetExternalMetric(ctx context.Context, namespace, metric string, metricSelector labels.Selector) ([]emv1beta1.ExternalMetricValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := c.external.NamespacedMetrics(namespace).List(metric, orEverything(metricSelector))
+	if err != nil {
+		return nil, err
+	}
+
+	return values.Items, nil
+}
+
+func orEverything(selector labels.Selector) labels.Selector {
+	if selector == nil {
+		return labels.Everything()
+	}
+
+	return selector
+}
@@ -0,0 +1,230 @@
+package metricscraper
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// AllPodMetricsResult is a sample of the metrics of all pods in a namespace
+// taken at Time, or the error taking it. Dropped counts the samples before it
+// which were dropped as the consumer did not keep up.
+type AllPodMetricsResult struct {
+	Time    time.Time
+	Metrics []v1beta1.PodMetrics
+	Dropped int
+	Err     error
+}
+
+// PodMetricsResult is a sample of the metrics of a pod taken at Time, or the
+// error taking it. Dropped counts the samples before it which were dropped as
+// the consumer did not keep up.
+type PodMetricsResult struct {
+	Time    time.Time
+	Metrics *v1beta1.PodMetrics
+	Dropped int
+	Err     error
+}
+
+// DeploymentMetrics is the resource usage of a deployment, in cores of CPU and
+// bytes of memory, averaged over the containers of its pods. Timestamp is when
+// the oldest sample among its pods was taken and Window the longest window a
+// sample was taken over, so that stale metrics can be spotted.
+type DeploymentMetrics struct {
+	CPU       float64
+	Memory    float64
+	Pods      int
+	Timestamp time.Time
+	Window    time.Duration
+}
+
+// DeploymentMetricsResult is a sample of the metrics of deployments taken at
+// Time, keyed by deployment name, or the error taking it. Dropped counts the
+// samples before it which were dropped as the consumer did not keep up.
+type DeploymentMetricsResult struct {
+	Time    time.Time
+	Metrics map[string]DeploymentMetrics
+	Dropped int
+	Err     error
+}
+
+// StreamAllPodMetrics returns a channel that has the metrics of all pods in a
+// namespace put in it right away and then every freq Duration. A consumer
+// which falls behind only ever finds the latest sample waiting, and the
+// channel is closed once ctx is done.
+func (c *Client) StreamAllPodMetrics(ctx context.Context, namespace string, freq time.Duration) <-chan AllPodMetricsResult {
+	metricChan := make(chan AllPodMetricsResult, 1)
+	go func() {
+		defer close(metricChan)
+
+		poll(ctx, freq, func() {
+			metrics, err := c.GetAllPodMetrics(ctx, namespace)
+			if ctx.Err() != nil {
+				return
+			}
+
+			r := AllPodMetricsResult{Time: time.Now(), Metrics: metrics, Err: err}
+			select {
+			case metricChan <- r:
+				return
+			default:
+			}
+
+			// The consumer is behind, replace the sample it has not read yet
+			select {
+			case old := <-metricChan:
+				r.Dropped += old.Dropped + 1
+			default:
+			}
+			metricChan <- r
+		})
+	}()
+
+	return metricChan
+}
+
+// StreamPodMetrics returns a channel that has the metrics of a named pod put
+// in it right away and then every freq Duration. A consumer which falls
+// behind only ever finds the latest sample waiting, and the channel is closed
+// once ctx is done.
+func (c *Client) StreamPodMetrics(ctx context.Context, namespace, name string, freq time.Duration) <-chan PodMetricsResult {
+	metricChan := make(chan PodMetricsResult, 1)
+	go func() {
+		defer close(metricChan)
+
+		poll(ctx, freq, func() {
+			metrics, err := c.GetPodMetrics(ctx, namespace, name)
+			if ctx.Err() != nil {
+				return
+			}
+
+			r := PodMetricsResult{Time: time.Now(), Metrics: metrics, Err: err}
+			select {
+			case metricChan <- r:
+				return
+			default:
+			}
+
+			// The consumer is behind, replace the sample it has not read yet
+			select {
+			case old := <-metricChan:
+				r.Dropped += old.Dropped + 1
+			default:
+			}
+			metricChan <- r
+		})
+	}()
+
+	return metricChan
+}
+
+// StreamDeploymentMetrics returns a channel that has the metrics of
+// deployments in a namespace put in it right away and then every freq
+// Duration. Pods belong to the deployments whose selectors, keyed by
+// deployment name, match their labels. It is built on StreamAllPodMetrics and
+// behaves the same way towards slow consumers and on ctx being done.
+func (c *Client) StreamDeploymentMetrics(ctx context.Context, namespace string, selectors map[string]labels.Selector, freq time.Duration) <-chan DeploymentMetricsResult {
+	metricChan := make(chan DeploymentMetricsResult, 1)
+	go func() {
+		defer close(metricChan)
+
+		for pods := range c.StreamAllPodMetrics(ctx, namespace, freq) {
+			r := DeploymentMetricsResult{Time: pods.Time, Dropped: pods.Dropped, Err: pods.Err}
+			if pods.Err == nil {
+				r.Metrics = AggregateDeploymentMetrics(pods.Metrics, selectors)
+			}
+
+			select {
+			case metricChan <- r:
+				continue
+			default:
+			}
+
+			// The consumer is behind, replace the sample it has not read yet
+			select {
+			case old := <-metricChan:
+				r.Dropped += old.Dropped + 1
+			default:
+			}
+			metricChan <- r
+		}
+	}()
+
+	return metricChan
+}
+
+// AggregateDeploymentMetrics averages the usage of the containers of pods by
+// the deployments whose selectors, keyed by deployment name, match their
+// labels. Deployments none of whose pods have metrics are left out.
+func AggregateDeploymentMetrics(pods []v1beta1.PodMetrics, selectors map[string]labels.Selector) map[string]DeploymentMetrics {
+	type sums struct {
+		cpu, memory      float64
+		pods, containers int
+		timestamp        time.Time
+		window           time.Duration
+	}
+
+	byDeployment := make(map[string]*sums)
+	for _, pod := range pods {
+		podLabels := labels.Set(pod.Labels)
+		for dep, selector := range selectors {
+			if selector == nil || selector.Empty() || !selector.Matches(podLabels) {
+				continue
+			}
+
+			sum, ok := byDeployment[dep]
+			if !ok {
+				sum = &sums{}
+				byDeployment[dep] = sum
+			}
+
+			sum.pods++
+			if sum.timestamp.IsZero() || pod.Timestamp.Time.Before(sum.timestamp) {
+				sum.timestamp = pod.Timestamp.Time
+			}
+			if pod.Window.Duration > sum.window {
+				sum.window = pod.Window.Duration
+			}
+			for _, container := range pod.Containers {
+				sum.cpu += container.Usage.Cpu().AsApproximateFloat64()
+				sum.memory += container.Usage.Memory().AsApproximateFloat64()
+				sum.containers++
+			}
+		}
+	}
+
+	metrics := make(map[string]DeploymentMetrics)
+	for dep, sum := range byDeployment {
+		if sum.containers == 0 {
+			continue
+		}
+
+		metrics[dep] = DeploymentMetrics{
+			CPU:       sum.cpu / float64(sum.containers),
+			Memory:    sum.memory / float64(sum.containers),
+			Pods:      sum.pods,
+			Timestamp: sum.timestamp,
+			Window:    sum.window,
+		}
+	}
+
+	return metrics
+}
+
+// poll calls fetch right away and then every freq until ctx is done
+func poll(ctx context.Context, freq time.Duration, fetch func()) {
+	ticker := time.NewTicker(freq)
+	defer ticker.Stop()
+
+	for {
+		fetch()
+
+		select {
+		case <-ctx.Done():
+			return // prevent goroutine leak.
+		case <-ticker.C:
+		}
+	}
+}
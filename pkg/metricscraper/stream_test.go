@@ -0,0 +1,201 @@
+package metricscraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// podMetrics returns the metrics of a pod labelled with app, with a single
+// container using cpu and memory
+func podMetrics(name, app, cpu, memory string) v1beta1.PodMetrics {
+	return v1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": app}},
+		Window:     metav1.Duration{Duration: 30 * time.Second},
+		Containers: []v1beta1.ContainerMetrics{{
+			Name: app,
+			Usage: apiv1.ResourceList{
+				apiv1.ResourceCPU:    resource.MustParse(cpu),
+				apiv1.ResourceMemory: resource.MustParse(memory),
+			},
+		}},
+	}
+}
+
+// newFakeMetricClient returns a client whose pod metrics are listed by list,
+// called with how many times pod metrics were listed so far
+func newFakeMetricClient(list func(n int) ([]v1beta1.PodMetrics, error)) *Client {
+	clientset := fake.NewSimpleClientset()
+
+	n := 0
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		n++
+		pods, err := list(n)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, &v1beta1.PodMetricsList{Items: pods}, nil
+	})
+
+	return &Client{client: clientset}
+}
+
+// expectClosed fails unless the channel is closed without handing out
+// another sample
+func expectClosed(t *testing.T, metricChan <-chan AllPodMetricsResult) {
+	t.Helper()
+
+	select {
+	case r, ok := <-metricChan:
+		if ok {
+			t.Fatalf("expected the channel to be closed, got a sample of %d pods", len(r.Metrics))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the channel to be closed on cancel")
+	}
+}
+
+func TestStreamAllPodMetricsSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every listing is announced on fetched and then held back until it is
+	// released, or until done
+	fetched := make(chan int)
+	release := make(chan struct{})
+	done := make(chan struct{})
+	c := newFakeMetricClient(func(n int) ([]v1beta1.PodMetrics, error) {
+		select {
+		case fetched <- n:
+		case <-done:
+		}
+		select {
+		case <-release:
+		case <-done:
+		}
+		return []v1beta1.PodMetrics{podMetrics(fmt.Sprint(n), "a", "100m", "1Mi")}, nil
+	})
+
+	metricChan := c.StreamAllPodMetrics(ctx, "default", time.Millisecond)
+
+	// Once the sixth listing is under way, the first five have been put in
+	// the channel without being read
+	for i := 1; i <= 5; i++ {
+		<-fetched
+		release <- struct{}{}
+	}
+	<-fetched
+
+	r := <-metricChan
+	if r.Err != nil {
+		t.Fatalf("expected no error, got %v", r.Err)
+	}
+	if len(r.Metrics) != 1 || r.Metrics[0].Name != "5" {
+		t.Errorf("expected the fifth sample, got %v", r.Metrics)
+	}
+	if r.Dropped != 4 {
+		t.Errorf("expected 4 dropped samples, got %d", r.Dropped)
+	}
+
+	// The listing under way when ctx is done is thrown away
+	cancel()
+	close(done)
+	expectClosed(t, metricChan)
+}
+
+func TestStreamAllPodMetricsError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newFakeMetricClient(func(n int) ([]v1beta1.PodMetrics, error) {
+		return nil, errors.New("metrics unavailable")
+	})
+
+	r := <-c.StreamAllPodMetrics(ctx, "default", time.Hour)
+	if r.Err == nil || r.Metrics != nil {
+		t.Fatalf("expected an error and no metrics, got %v and %v", r.Err, r.Metrics)
+	}
+	if r.Dropped != 0 {
+		t.Errorf("expected no dropped samples, got %d", r.Dropped)
+	}
+}
+
+func TestStreamAllPodMetricsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := newFakeMetricClient(func(n int) ([]v1beta1.PodMetrics, error) {
+		return nil, nil
+	})
+
+	metricChan := c.StreamAllPodMetrics(ctx, "default", time.Hour)
+	<-metricChan
+
+	// Waiting on the next tick, the stream stops as soon as ctx is done
+	cancel()
+	expectClosed(t, metricChan)
+}
+
+func TestStreamDeploymentMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := newFakeMetricClient(func(n int) ([]v1beta1.PodMetrics, error) {
+		return []v1beta1.PodMetrics{
+			podMetrics("a-1", "a", "100m", "1Mi"),
+			podMetrics("a-2", "a", "300m", "3Mi"),
+			podMetrics("b-1", "b", "1", "8Mi"),
+		}, nil
+	})
+
+	selectors := map[string]labels.Selector{
+		"a": labels.SelectorFromSet(labels.Set{"app": "a"}),
+		"b": labels.SelectorFromSet(labels.Set{"app": "b"}),
+		"c": labels.SelectorFromSet(labels.Set{"app": "c"}),
+	}
+
+	metricChan := c.StreamDeploymentMetrics(ctx, "default", selectors, time.Hour)
+	r := <-metricChan
+	if r.Err != nil {
+		t.Fatalf("expected no error, got %v", r.Err)
+	}
+
+	cases := []struct {
+		dep    string
+		cpu    float64
+		memory float64
+		pods   int
+	}{
+		{"a", 0.2, 2 * 1024 * 1024, 2},
+		{"b", 1, 8 * 1024 * 1024, 1},
+	}
+
+	if len(r.Metrics) != len(cases) {
+		t.Errorf("expected metrics of %d deployments, got %v", len(cases), r.Metrics)
+	}
+	for _, c := range cases {
+		m := r.Metrics[c.dep]
+		if m.CPU != c.cpu || m.Memory != c.memory || m.Pods != c.pods {
+			t.Errorf("%s: expected cpu %.2f, memory %.0f over %d pods, got %+v", c.dep, c.cpu, c.memory, c.pods, m)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-metricChan:
+		if ok {
+			t.Fatal("expected the channel to be closed without another sample")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the channel to be closed on cancel")
+	}
+}
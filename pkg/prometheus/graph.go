@@ -0,0 +1,376 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+	"golang.org/x/sync/errgroup"
+)
+
+// Istio metrics the graph is built from
+const (
+	metricRequests         = "istio_requests_total"
+	metricDurationSum      = "istio_request_duration_milliseconds_sum"
+	metricDurationCount    = "istio_request_duration_milliseconds_count"
+	metricResponseBytesSum = "istio_response_bytes_sum"
+)
+
+// Labels identifying an edge between two workloads
+var edgeLabels = []string{
+	"source_workload_namespace",
+	"source_workload",
+	"source_app",
+	"source_version",
+	"destination_workload_namespace",
+	"destination_workload",
+	"destination_app",
+	"destination_version",
+	"request_protocol",
+}
+
+// unknownWorkload is what istio reports as the workload of traffic from
+// outside the mesh
+const unknownWorkload = "unknown"
+
+// ingressGatewayApp is the app label of Istio ingress gateways
+const ingressGatewayApp = "istio-ingressgateway"
+
+// GraphQuery describes the workload graph to build from istio telemetry: the
+// traffic of workloads in Namespaces over the Duration leading up to
+// QueryTime, or up to now if QueryTime is zero.
+type GraphQuery struct {
+	Namespaces []string
+	Duration   time.Duration
+	QueryTime  time.Time
+}
+
+// Validate checks that a query describes a graph that can be built.
+func (q GraphQuery) Validate() error {
+	if len(q.Namespaces) == 0 {
+		return fmt.Errorf("no namespaces to build a graph of")
+	}
+	if q.Duration < time.Second {
+		return fmt.Errorf("duration must be at least a second, got %v", q.Duration)
+	}
+
+	return nil
+}
+
+// rateQuery returns a query for the per second rate of a counter summed by the
+// given labels. It covers the requests into workloads in the namespaces of the
+// graph as their destination proxies report them, which includes requests from
+// outside the mesh, and the requests from those workloads to workloads in
+// other namespaces as their source proxies report them.
+func (q GraphQuery) rateQuery(metric string, by []string) string {
+	// Namespace names hold nothing special to a regular expression
+	pattern := strings.Join(q.Namespaces, "|")
+
+	window := fmt.Sprintf("%ds", int64(q.Duration/time.Second))
+	labels := strings.Join(by, ",")
+
+	inbound := fmt.Sprintf(`reporter="destination",destination_workload_namespace=~"%s"`, pattern)
+	outbound := fmt.Sprintf(`reporter="source",source_workload_namespace=~"%s",destination_workload_namespace!~"%s"`, pattern, pattern)
+
+	return fmt.Sprintf(
+		"sum(rate(%s{%s}[%s])) by (%s) or sum(rate(%s{%s}[%s])) by (%s)",
+		metric, inbound, window, labels,
+		metric, outbound, window, labels,
+	)
+}
+
+// edgeTraffic accumulates what the samples of an edge add up to
+type edgeTraffic struct {
+	source, target string
+	protocol       string
+
+	rate          float64
+	errorRate     float64
+	rates         map[string]float64
+	durationSum   float64
+	durationCount float64
+	responseBytes float64
+}
+
+// GetGraph builds the workload graph described by a query straight from
+// istio telemetry, in the same shape as the graphs kiali serves. Edges carry
+// their request rate and percentage of failed requests by protocol, their
+// average response time in milliseconds and their response throughput in
+// bytes per second. Traffic from outside the mesh comes from the "unknown"
+// node, nodes no traffic enters are roots, and nodes outside the namespaces
+// of the query are marked as such.
+func (pc *Client) GetGraph(ctx context.Context, query GraphQuery) (kiali.Graph, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	codeLabels := append(append([]string{}, edgeLabels...), "response_code", "grpc_response_status")
+
+	var requests, durationSums, durationCounts, responseBytes Vector
+	eg, egCtx := errgroup.WithContext(ctx)
+
+	queries := []struct {
+		metric string
+		by     []string
+		result *Vector
+	}{
+		{metricRequests, codeLabels, &requests},
+		{metricDurationSum, edgeLabels, &durationSums},
+		{metricDurationCount, edgeLabels, &durationCounts},
+		{metricResponseBytesSum, edgeLabels, &responseBytes},
+	}
+	for _, q := range queries {
+		q := q
+		eg.Go(func() error {
+			vector, err := pc.Query(egCtx, query.rateQuery(q.metric, q.by), query.QueryTime)
+			*q.result = vector
+			return err
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	b := newGraphBuilder(query.Namespaces)
+	for _, sample := range requests {
+		edge := b.edge(sample.Metric)
+		if edge == nil {
+			continue
+		}
+
+		rate := sample.Value.Value
+		edge.rate += rate
+		if isError(edge.protocol, sample.Metric["response_code"], sample.Metric["grpc_response_status"]) {
+			edge.errorRate += rate
+		}
+		if class := codeClass(edge.protocol, sample.Metric["response_code"]); class != "" {
+			edge.rates[class] += rate
+		}
+	}
+	for _, sample := range durationSums {
+		if edge := b.edge(sample.Metric); edge != nil {
+			edge.durationSum += sample.Value.Value
+		}
+	}
+	for _, sample := range durationCounts {
+		if edge := b.edge(sample.Metric); edge != nil {
+			edge.durationCount += sample.Value.Value
+		}
+	}
+	for _, sample := range responseBytes {
+		if edge := b.edge(sample.Metric); edge != nil {
+			edge.responseBytes += sample.Value.Value
+		}
+	}
+
+	g, _ := kiali.MakeGraph(b.config(query))
+	return g, nil
+}
+
+// graphBuilder collects the nodes and edges found in samples
+type graphBuilder struct {
+	namespaces map[string]bool
+	nodes      map[string]*graph.NodeData
+	edges      map[string]*edgeTraffic
+}
+
+func newGraphBuilder(namespaces []string) *graphBuilder {
+	b := &graphBuilder{
+		namespaces: make(map[string]bool),
+		nodes:      make(map[string]*graph.NodeData),
+		edges:      make(map[string]*edgeTraffic),
+	}
+	for _, namespace := range namespaces {
+		b.namespaces[namespace] = true
+	}
+
+	return b
+}
+
+// node returns the ID of the node for a workload, adding it if it is new
+func (b *graphBuilder) node(namespace, workload, app, version string) string {
+	if workload == unknownWorkload || workload == "" {
+		if _, ok := b.nodes[unknownWorkload]; !ok {
+			b.nodes[unknownWorkload] = &graph.NodeData{
+				ID:        unknownWorkload,
+				NodeType:  kiali.NodeTypeUnknown,
+				Namespace: unknownWorkload,
+				Workload:  unknownWorkload,
+			}
+		}
+		return unknownWorkload
+	}
+
+	id := namespace + "/" + workload
+	node, ok := b.nodes[id]
+	if !ok {
+		node = &graph.NodeData{
+			ID:        id,
+			NodeType:  kiali.NodeTypeWorkload,
+			Namespace: namespace,
+			Workload:  workload,
+			IsOutside: !b.namespaces[namespace],
+		}
+		b.nodes[id] = node
+	}
+
+	// Not every sample of a workload carries its app labels
+	if node.App == "" && app != "" {
+		node.App = app
+		node.Version = version
+		if app == ingressGatewayApp {
+			node.IsGateway = &graph.GWInfo{}
+		}
+	}
+
+	return id
+}
+
+// edge returns the traffic of the edge a sample belongs to, adding the edge
+// and its nodes if they are new. Samples of requests to no known workload are
+// left out.
+func (b *graphBuilder) edge(labels map[string]string) *edgeTraffic {
+	target := labels["destination_workload"]
+	if target == "" || target == unknownWorkload {
+		return nil
+	}
+
+	sourceID := b.node(labels["source_workload_namespace"], labels["source_workload"], labels["source_app"], labels["source_version"])
+	targetID := b.node(labels["destination_workload_namespace"], target, labels["destination_app"], labels["destination_version"])
+
+	protocol := labels["request_protocol"]
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	key := sourceID + " " + targetID + " " + protocol
+	edge, ok := b.edges[key]
+	if !ok {
+		edge = &edgeTraffic{
+			source:   sourceID,
+			target:   targetID,
+			protocol: protocol,
+			rates:    make(map[string]float64),
+		}
+		b.edges[key] = edge
+	}
+
+	return edge
+}
+
+// config returns the collected nodes and edges as a kiali graph config.
+// Nodes no edge leads into are marked as roots.
+func (b *graphBuilder) config(query GraphQuery) *graph.Config {
+	hasInbound := make(map[string]bool)
+	for _, edge := range b.edges {
+		hasInbound[edge.target] = true
+	}
+
+	conf := &graph.Config{
+		Duration:  int64(query.Duration / time.Second),
+		GraphType: kiali.GraphTypeWorkload,
+		Elements: graph.Elements{
+			Nodes: []*graph.NodeWrapper{},
+			Edges: []*graph.EdgeWrapper{},
+		},
+	}
+	if !query.QueryTime.IsZero() {
+		conf.Timestamp = query.QueryTime.Unix()
+	} else {
+		conf.Timestamp = time.Now().Unix()
+	}
+
+	for _, id := range sortedNodeIDs(b.nodes) {
+		node := b.nodes[id]
+		node.IsRoot = !hasInbound[id]
+		conf.Elements.Nodes = append(conf.Elements.Nodes, &graph.NodeWrapper{Data: node})
+	}
+
+	for i, key := range sortedEdgeKeys(b.edges) {
+		edge := b.edges[key]
+
+		rates := map[string]string{
+			edge.protocol: formatFloat(edge.rate, 2),
+		}
+		if edge.rate > 0 && edge.protocol != "tcp" {
+			rates[edge.protocol+"PercentErr"] = formatFloat(100*edge.errorRate/edge.rate, 1)
+		}
+		for class, rate := range edge.rates {
+			rates[class] = formatFloat(rate, 2)
+		}
+
+		data := &graph.EdgeData{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: edge.source,
+			Target: edge.target,
+			Traffic: graph.ProtocolTraffic{
+				Protocol: edge.protocol,
+				Rates:    rates,
+			},
+			Throughput: formatFloat(edge.responseBytes, 0),
+		}
+		if edge.durationCount > 0 {
+			data.ResponseTime = formatFloat(edge.durationSum/edge.durationCount, 2)
+		}
+
+		conf.Elements.Edges = append(conf.Elements.Edges, &graph.EdgeWrapper{Data: data})
+	}
+
+	return conf
+}
+
+// isError reports whether requests with the given response code and gRPC
+// status failed. HTTP 5xx responses, requests with no response and non-OK gRPC
+// statuses are failures, as in kiali health.
+func isError(protocol, code, grpcStatus string) bool {
+	if code == "0" || code == "-" {
+		return true
+	}
+
+	if protocol == "grpc" {
+		return grpcStatus != "" && grpcStatus != "0"
+	}
+
+	status, err := strconv.Atoi(code)
+	return err == nil && status >= 500
+}
+
+// codeClass returns the key kiali keeps the rate of HTTP responses of a class
+// under, e.g. "http5xx", or "" for other protocols
+func codeClass(protocol, code string) string {
+	if protocol != "http" || len(code) != 3 || code[0] < '3' || code[0] > '5' {
+		return ""
+	}
+
+	return "http" + code[:1] + "xx"
+}
+
+func formatFloat(f float64, prec int) string {
+	return strconv.FormatFloat(f, 'f', prec, 64)
+}
+
+func sortedNodeIDs(nodes map[string]*graph.NodeData) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func sortedEdgeKeys(edges map[string]*edgeTraffic) []string {
+	keys := make([]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
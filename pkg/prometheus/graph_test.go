@@ -0,0 +1,169 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// series is a canned sample of a fake prometheus vector
+type series struct {
+	labels string
+	value  string
+}
+
+// edgeSeries returns a sample of the edge described as "<source namespace>
+// <source workload> <destination namespace> <destination workload>
+// <protocol>", with extra labels appended
+func edgeSeries(edge string, extra string, value string) series {
+	f := strings.Fields(edge)
+	labels := fmt.Sprintf(
+		`"source_workload_namespace":%q,"source_workload":%q,"source_app":%q,"destination_workload_namespace":%q,"destination_workload":%q,"destination_app":%q,"request_protocol":%q`,
+		f[0], f[1], f[1], f[2], f[3], f[3], f[4],
+	)
+	if extra != "" {
+		labels += "," + extra
+	}
+
+	return series{labels: labels, value: value}
+}
+
+// respondWithVectors answers every query with the canned vector of the metric
+// it is on, and records the queries
+func respondWithVectors(vectors map[string][]series, queries *[]string, mu *sync.Mutex) func(w http.ResponseWriter, query string) {
+	return func(w http.ResponseWriter, query string) {
+		mu.Lock()
+		*queries = append(*queries, query)
+		mu.Unlock()
+
+		results := []string{}
+		for metric, samples := range vectors {
+			if !strings.Contains(query, metric+"{") {
+				continue
+			}
+			for _, s := range samples {
+				results = append(results, fmt.Sprintf(`{"metric":{%s},"value":[1600000000,%q]}`, s.labels, s.value))
+			}
+		}
+
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[%s]}}`, strings.Join(results, ","))
+	}
+}
+
+func TestGetGraph(t *testing.T) {
+	vectors := map[string][]series{
+		metricRequests: {
+			edgeSeries("unknown unknown shop front http", `"response_code":"200"`, "9"),
+			edgeSeries("unknown unknown shop front http", `"response_code":"503"`, "1"),
+			edgeSeries("shop front shop back http", `"response_code":"200"`, "5"),
+			edgeSeries("shop back pay charge grpc", `"response_code":"200","grpc_response_status":"0"`, "3"),
+			edgeSeries("shop back pay charge grpc", `"response_code":"200","grpc_response_status":"14"`, "1"),
+			// Requests to no known workload are left out
+			edgeSeries("shop back unknown unknown http", `"response_code":"404"`, "2"),
+		},
+		metricDurationSum: {
+			edgeSeries("unknown unknown shop front http", "", "300"),
+			edgeSeries("shop front shop back http", "", "500"),
+		},
+		metricDurationCount: {
+			edgeSeries("unknown unknown shop front http", "", "10"),
+			edgeSeries("shop front shop back http", "", "5"),
+		},
+		metricResponseBytesSum: {
+			edgeSeries("shop front shop back http", "", "2048"),
+		},
+	}
+
+	var mu sync.Mutex
+	queries := []string{}
+	pc := newFakePrometheus(t, respondWithVectors(vectors, &queries, &mu))
+
+	g, err := pc.GetGraph(context.Background(), GraphQuery{Namespaces: []string{"shop"}, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 4 {
+		t.Fatalf("expected a query per metric, got %d", len(queries))
+	}
+	for _, query := range queries {
+		if !strings.Contains(query, `destination_workload_namespace=~"shop"`) || !strings.Contains(query, "[60s]") {
+			t.Errorf("expected the query to cover the namespace over the duration, got %q", query)
+		}
+	}
+
+	ids := []string{}
+	for id := range g {
+		ids = append(ids, id)
+	}
+	if len(g) != 4 {
+		t.Fatalf("expected the unknown, front, back and charge nodes, got %v", ids)
+	}
+
+	for id, item := range g {
+		if wantRoot := id == unknownWorkload; item.IsRoot != wantRoot {
+			t.Errorf("%s: expected root to be %v", id, wantRoot)
+		}
+		if wantOutside := id == "pay/charge"; item.IsOutside != wantOutside {
+			t.Errorf("%s: expected outside to be %v", id, wantOutside)
+		}
+	}
+
+	edge := onlyEdge(t, g["unknown"].Edges, "shop/front")
+	if rates := edge.Traffic.Rates; rates["http"] != "10.00" || rates["httpPercentErr"] != "10.0" || rates["http5xx"] != "1.00" {
+		t.Errorf("unexpected rates into front: %v", rates)
+	}
+	if edge.ResponseTime != "30.00" {
+		t.Errorf("expected a response time of 30ms into front, got %q", edge.ResponseTime)
+	}
+
+	edge = onlyEdge(t, g["shop/front"].Edges, "shop/back")
+	if rates := edge.Traffic.Rates; rates["http"] != "5.00" || rates["httpPercentErr"] != "0.0" {
+		t.Errorf("unexpected rates into back: %v", rates)
+	}
+	if edge.ResponseTime != "100.00" || edge.Throughput != "2048" {
+		t.Errorf("expected 100ms and 2048 bytes into back, got %q and %q", edge.ResponseTime, edge.Throughput)
+	}
+
+	edge = onlyEdge(t, g["shop/back"].Edges, "pay/charge")
+	if rates := edge.Traffic.Rates; edge.Traffic.Protocol != "grpc" || rates["grpc"] != "4.00" || rates["grpcPercentErr"] != "25.0" {
+		t.Errorf("unexpected grpc rates into charge: %v", rates)
+	}
+	if edge.ResponseTime != "" {
+		t.Errorf("expected no response time without durations, got %q", edge.ResponseTime)
+	}
+}
+
+func TestGetGraphQueryError(t *testing.T) {
+	pc := newFakePrometheus(t, respondWith(http.StatusServiceUnavailable, `{"status":"error","errorType":"unavailable","error":"down"}`))
+
+	if _, err := pc.GetGraph(context.Background(), GraphQuery{Namespaces: []string{"shop"}, Duration: time.Minute}); err == nil {
+		t.Fatal("expected a failed query to fail the graph")
+	}
+}
+
+// onlyEdge returns the single edge to target among edges
+func onlyEdge(t *testing.T, edges []*graph.EdgeData, target string) *graph.EdgeData {
+	t.Helper()
+
+	var found *graph.EdgeData
+	for _, edge := range edges {
+		if edge.Target == target {
+			if found != nil {
+				t.Fatalf("expected a single edge to %s", target)
+			}
+			found = edge
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected an edge to %s", target)
+	}
+
+	return found
+}
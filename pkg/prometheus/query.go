@@ -0,0 +1,164 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
+
+// Errors returned by the prometheus client. They are wrapped in a QueryError,
+// so callers should compare against them with errors.Is.
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrBadQuery         = errors.New("bad query")
+	ErrServerError      = errors.New("server error")
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	ErrUnexpectedResult = errors.New("unexpected result type")
+	ErrDecode           = errors.New("could not decode response")
+)
+
+// maxResponseSize is the largest response body read from prometheus
+const maxResponseSize = 32 << 20
+
+// QueryError is returned for queries prometheus answered with an error, or
+// whose response could not be used. Type and Message are prometheus' own
+// errorType and error when it gave them.
+type QueryError struct {
+	Query      string
+	StatusCode int
+	Type       string
+	Message    string
+	Err        error
+}
+
+func (e *QueryError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("prometheus query %q failed with status %d: %v: %s: %s", e.Query, e.StatusCode, e.Err, e.Type, e.Message)
+	}
+	return fmt.Sprintf("prometheus query %q failed with status %d: %v", e.Query, e.StatusCode, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Sample is a single value of an instant vector along with the labels of its
+// series
+type Sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  kiali.Datapoint   `json:"value"`
+}
+
+// Vector is the result of an instant query
+type Vector []Sample
+
+// queryResponse is the envelope of every prometheus API response
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Query evaluates an instant query at the given time, or now if it is zero.
+// Samples which are not a number are left out.
+func (pc *Client) Query(ctx context.Context, query string, ts time.Time) (Vector, error) {
+	u := pc.url("api/v1/query")
+	q := u.Query()
+	q.Set("query", query)
+	if !ts.IsZero() {
+		q.Set("time", strconv.FormatInt(ts.Unix(), 10))
+	}
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, pc.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := pc.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	// Prometheus describes failed queries in the body of error responses too
+	qr := queryResponse{}
+	decodeErr := json.Unmarshal(body, &qr)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || qr.Status == "error" {
+		var err error
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			err = ErrUnauthorized
+		case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity:
+			err = ErrBadQuery
+		case resp.StatusCode >= 500:
+			err = ErrServerError
+		default:
+			err = ErrUnexpectedStatus
+		}
+
+		return nil, &QueryError{
+			Query:      query,
+			StatusCode: resp.StatusCode,
+			Type:       qr.ErrorType,
+			Message:    qr.Error,
+			Err:        err,
+		}
+	}
+
+	if decodeErr != nil {
+		return nil, &QueryError{
+			Query:      query,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%w: %v", ErrDecode, decodeErr),
+		}
+	}
+
+	if qr.Data.ResultType != "vector" {
+		return nil, &QueryError{
+			Query:      query,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%w: %q", ErrUnexpectedResult, qr.Data.ResultType),
+		}
+	}
+
+	vector := Vector{}
+	if err := json.Unmarshal(qr.Data.Result, &vector); err != nil {
+		return nil, &QueryError{
+			Query:      query,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%w: %v", ErrDecode, err),
+		}
+	}
+
+	samples := vector[:0]
+	for _, sample := range vector {
+		if !math.IsNaN(sample.Value.Value) && !math.IsInf(sample.Value.Value, 0) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,150 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newFakePrometheus starts a server answering instant queries with whatever
+// respond writes for them
+func newFakePrometheus(t *testing.T, respond func(w http.ResponseWriter, query string)) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		respond(w, r.URL.Query().Get("query"))
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewPrometheusClient("http", host, port, nil)
+}
+
+// respondWith answers every query with the given status and body
+func respondWith(status int, body string) func(w http.ResponseWriter, query string) {
+	return func(w http.ResponseWriter, query string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestQueryErrorStatus(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`, ErrBadQuery},
+		{http.StatusUnprocessableEntity, `{"status":"error","errorType":"execution","error":"too many samples"}`, ErrBadQuery},
+		{http.StatusUnauthorized, `unauthorized`, ErrUnauthorized},
+		{http.StatusServiceUnavailable, `{"status":"error","errorType":"unavailable","error":"shutting down"}`, ErrServerError},
+		{http.StatusOK, `{"status":"error","errorType":"internal","error":"oops"}`, ErrUnexpectedStatus},
+	}
+
+	for _, c := range cases {
+		pc := newFakePrometheus(t, respondWith(c.status, c.body))
+
+		_, err := pc.Query(context.Background(), "up", time.Time{})
+		if !errors.Is(err, c.want) {
+			t.Errorf("status %d: expected %v, got %v", c.status, c.want, err)
+			continue
+		}
+
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("status %d: expected a QueryError, got %T", c.status, err)
+			continue
+		}
+		if qe.StatusCode != c.status || qe.Query != "up" {
+			t.Errorf("status %d: unexpected query error %+v", c.status, qe)
+		}
+	}
+
+	pc := newFakePrometheus(t, respondWith(http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	_, err := pc.Query(context.Background(), "up{", time.Time{})
+	var qe *QueryError
+	if !errors.As(err, &qe) || qe.Type != "bad_data" || qe.Message != "parse error" {
+		t.Fatalf("expected the error type and message of prometheus, got %v", err)
+	}
+}
+
+func TestQueryUnexpectedResult(t *testing.T) {
+	pc := newFakePrometheus(t, respondWith(http.StatusOK, `{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+
+	_, err := pc.Query(context.Background(), "up[1m]", time.Time{})
+	if !errors.Is(err, ErrUnexpectedResult) {
+		t.Fatalf("expected ErrUnexpectedResult for a matrix, got %v", err)
+	}
+}
+
+func TestQueryDecodeError(t *testing.T) {
+	pc := newFakePrometheus(t, respondWith(http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":[{"value":[1, 2]}]}}`))
+
+	_, err := pc.Query(context.Background(), "up", time.Time{})
+	if !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected ErrDecode for a value that is not a string, got %v", err)
+	}
+}
+
+func TestQueryDecodesSamples(t *testing.T) {
+	var gotQuery string
+	pc := newFakePrometheus(t, func(w http.ResponseWriter, query string) {
+		gotQuery = query
+		w.Write([]byte(`{
+			"status": "success",
+			"data": {
+				"resultType": "vector",
+				"result": [
+					{"metric": {"destination_workload": "a"}, "value": [1600000000.5, "1.25"]},
+					{"metric": {"destination_workload": "b"}, "value": [1600000000.5, "NaN"]},
+					{"metric": {"destination_workload": "c"}, "value": [1600000000.5, "+Inf"]},
+					{"metric": {}, "value": [1600000000.5, "0"]}
+				]
+			}
+		}`))
+	})
+
+	vector, err := pc.Query(context.Background(), `sum(rate(istio_requests_total[1m]))`, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotQuery != `sum(rate(istio_requests_total[1m]))` {
+		t.Fatalf("expected the query to be sent as is, got %q", gotQuery)
+	}
+
+	if len(vector) != 2 {
+		t.Fatalf("expected the samples that are not a number to be left out, got %+v", vector)
+	}
+	if vector[0].Metric["destination_workload"] != "a" || vector[0].Value.Value != 1.25 {
+		t.Fatalf("unexpected first sample %+v", vector[0])
+	}
+	if want := time.Unix(1600000000, int64(500*time.Millisecond)); !vector[0].Value.Time.Equal(want) {
+		t.Fatalf("expected the sample to be taken at %v, got %v", want, vector[0].Value.Time)
+	}
+	if len(vector[1].Metric) != 0 || vector[1].Value.Value != 0 {
+		t.Fatalf("unexpected second sample %+v", vector[1])
+	}
+}
@@ -0,0 +1,46 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GetQueueLength gives the queue length of a workload in a namespace from the
+// Istio telemetry its proxy reports for inbound requests, the rate of its
+// response bytes times its average request duration in milliseconds, both
+// taken over the window leading up to ts, or up to now if ts is zero. This is
+// the same queue length kiali graphs and metrics yield. A workload without
+// traffic over the window has a queue length of 0.
+func (c *Client) GetQueueLength(ctx context.Context, namespace, workload string, window time.Duration, ts time.Time) (float64, error) {
+	if window < time.Second {
+		return 0, fmt.Errorf("window must be at least a second, got %v", window)
+	}
+
+	selector := fmt.Sprintf(
+		`reporter="destination",destination_workload_namespace="%s",destination_workload="%s"`,
+		namespace,
+		workload,
+	)
+	rate := func(metric string) string {
+		return fmt.Sprintf("sum(rate(%s{%s}[%ds]))", metric, selector, int64(window/time.Second))
+	}
+
+	query := fmt.Sprintf(
+		"%s * (%s / %s)",
+		rate(metricResponseBytesSum),
+		rate(metricDurationSum),
+		rate(metricDurationCount),
+	)
+
+	vector, err := c.Query(ctx, query, ts)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(vector) == 0 {
+		return 0, nil
+	}
+
+	return vector[0].Value.Value, nil
+}
@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultRequestTimeout bounds every query sent to prometheus
+const defaultRequestTimeout = 30 * time.Second
+
+// Client is a type to help interact with the prometheus HTTP API
+type Client struct {
+	httpClient     *http.Client
+	scheme         string
+	host           string
+	requestTimeout time.Duration
+}
+
+// NewPrometheusClient is a constructor for type Client. The scheme defaults
+// to "http" when empty. If hc is nil, the default http client is used.
+func NewPrometheusClient(scheme, host string, port int, hc *http.Client) *Client {
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	return &Client{
+		httpClient:     hc,
+		scheme:         scheme,
+		host:           fmt.Sprintf("%s:%d", host, port),
+		requestTimeout: defaultRequestTimeout,
+	}
+}
+
+// SetRequestTimeout sets the timeout of every query.
+func (pc *Client) SetRequestTimeout(timeout time.Duration) {
+	pc.requestTimeout = timeout
+}
+
+// url returns the URL of a prometheus endpoint
+func (pc *Client) url(endpoint string) *url.URL {
+	return &url.URL{
+		Scheme: pc.scheme,
+		Host:   pc.host,
+		Path:   endpoint,
+	}
+}
@@ -0,0 +1,213 @@
+package traces
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// unknownService stands for callers outside the traced application
+const unknownService = "unknown"
+
+// call is a pair of services one calls the other
+type call struct {
+	caller, callee string
+}
+
+// callStats accumulates the calls seen from one service to another, along
+// with the response sizes of those which recorded one
+type callStats struct {
+	count   int
+	latency time.Duration
+	sized   int
+	bytes   int64
+}
+
+// CallGraph is the graph of calls between services found in traces
+type CallGraph struct {
+	// Requests into every service, calls between services along with their
+	// total latency, and the time they were seen over
+	requests map[string]int
+	calls    map[call]*callStats
+	window   time.Duration
+}
+
+// NewCallGraph finds the calls between services in traces seen over a window.
+// A span is a request into its service when its parent ran in another
+// service, which is then its caller, or when it has no parent, in which case
+// it was called from outside.
+func NewCallGraph(traces []Trace, window time.Duration) *CallGraph {
+	cg := &CallGraph{
+		requests: make(map[string]int),
+		calls:    make(map[call]*callStats),
+		window:   window,
+	}
+
+	for _, trace := range traces {
+		spans := make(map[string]Span)
+		for _, span := range trace {
+			spans[span.SpanID] = span
+		}
+
+		for _, span := range trace {
+			caller := unknownService
+			if parent, ok := spans[span.ParentID]; ok {
+				if parent.Service == span.Service {
+					continue
+				}
+				caller = parent.Service
+			}
+
+			cg.requests[span.Service]++
+
+			c := call{caller: caller, callee: span.Service}
+			stats, ok := cg.calls[c]
+			if !ok {
+				stats = &callStats{}
+				cg.calls[c] = stats
+			}
+			stats.count++
+			stats.latency += span.Duration
+			if span.ResponseSize >= 0 {
+				stats.sized++
+				stats.bytes += span.ResponseSize
+			}
+		}
+	}
+
+	return cg
+}
+
+// FanOut returns how many calls to callee every request into caller leads to
+func (cg *CallGraph) FanOut(caller, callee string) float64 {
+	stats, ok := cg.calls[call{caller: caller, callee: callee}]
+	if !ok || cg.requests[caller] == 0 {
+		return 0
+	}
+
+	return float64(stats.count) / float64(cg.requests[caller])
+}
+
+// Graph returns the call graph as a workload graph of a namespace, taking
+// every service to be the workload of the same name. Edges carry their mean
+// latency and their call rate, scaled up by the fraction of requests
+// sampleRate that were traced, as their request rate. Their throughput is
+// their call rate times the mean size of the responses their spans recorded,
+// in bytes per second as in the graphs of kiali, or times responseSize when
+// none of their spans recorded one. Calls from outside come from the unknown
+// node, the only root. Every node knows the fan-out of its edges.
+func (cg *CallGraph) Graph(namespace string, sampleRate, responseSize float64) kiali.Graph {
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	seconds := cg.window.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	calls := make([]call, 0, len(cg.calls))
+	for c := range cg.calls {
+		calls = append(calls, c)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].caller != calls[j].caller {
+			return calls[i].caller < calls[j].caller
+		}
+		return calls[i].callee < calls[j].callee
+	})
+
+	// Calls from outside count too, so that only the unknown node is a root
+	hasInbound := make(map[string]bool)
+	for _, c := range calls {
+		hasInbound[c.callee] = true
+	}
+
+	conf := &graph.Config{
+		Timestamp: time.Now().Unix(),
+		Duration:  int64(cg.window / time.Second),
+		GraphType: kiali.GraphTypeWorkload,
+	}
+
+	added := make(map[string]bool)
+	addNode := func(service string) {
+		if added[service] {
+			return
+		}
+		added[service] = true
+
+		node := &graph.NodeData{
+			ID:        service,
+			NodeType:  kiali.NodeTypeWorkload,
+			Namespace: namespace,
+			Workload:  service,
+			IsRoot:    !hasInbound[service],
+		}
+		if service == unknownService {
+			node.NodeType = kiali.NodeTypeUnknown
+			node.Namespace = unknownService
+		}
+		conf.Elements.Nodes = append(conf.Elements.Nodes, &graph.NodeWrapper{Data: node})
+	}
+
+	for i, c := range calls {
+		addNode(c.caller)
+		addNode(c.callee)
+
+		stats := cg.calls[c]
+		rate := float64(stats.count) / sampleRate / seconds
+		latency := stats.latency.Seconds() * 1000 / float64(stats.count)
+		size := responseSize
+		if stats.sized > 0 {
+			size = float64(stats.bytes) / float64(stats.sized)
+		}
+
+		conf.Elements.Edges = append(conf.Elements.Edges, &graph.EdgeWrapper{Data: &graph.EdgeData{
+			ID:           fmt.Sprintf("e%d", i),
+			Source:       c.caller,
+			Target:       c.callee,
+			ResponseTime: strconv.FormatFloat(latency, 'f', 2, 64),
+			Throughput:   strconv.FormatFloat(rate*size, 'f', 0, 64),
+			Traffic: graph.ProtocolTraffic{
+				Protocol: "http",
+				Rates:    map[string]string{"http": strconv.FormatFloat(rate, 'f', 2, 64)},
+			},
+		}})
+	}
+
+	g, _ := kiali.MakeGraph(conf)
+	for _, c := range calls {
+		if c.caller == unknownService {
+			continue
+		}
+
+		item := g[c.caller]
+		if item.FanOuts == nil {
+			item.FanOuts = make(map[string]float64)
+		}
+		item.FanOuts[c.callee] = cg.FanOut(c.caller, c.callee)
+	}
+
+	return g
+}
+
+// Observed returns the time from the start of the earliest span of traces to
+// the end of the latest, or 0 if there are none
+func Observed(traces []Trace) time.Duration {
+	var first, last time.Time
+	for _, trace := range traces {
+		for _, span := range trace {
+			if first.IsZero() || span.Start.Before(first) {
+				first = span.Start
+			}
+			if end := span.Start.Add(span.Duration); end.After(last) {
+				last = end
+			}
+		}
+	}
+
+	return last.Sub(first)
+}
@@ -0,0 +1,132 @@
+package traces
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JaegerClient reads traces from the Jaeger query API
+type JaegerClient struct {
+	httpReader
+}
+
+// NewJaegerClient is a constructor for type JaegerClient. Traces are fetched
+// for each of services, or for every service Jaeger knows if it is empty, at
+// most limit per service (100 when 0). The scheme defaults to "http" when
+// empty, and if hc is nil an http client with a 30 second timeout is used.
+func NewJaegerClient(scheme, host string, port int, hc *http.Client, services []string, limit int) *JaegerClient {
+	return &JaegerClient{newHTTPReader(scheme, host, port, hc, services, limit)}
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerTag struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	Tags          []jaegerTag       `json:"tags"`
+	StartTime     int64             `json:"startTime"`
+	Duration      int64             `json:"duration"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerTrace struct {
+	TraceID   string       `json:"traceID"`
+	Spans     []jaegerSpan `json:"spans"`
+	Processes map[string]struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"processes"`
+}
+
+// GetTraces fetches the traces which started within the window from Jaeger.
+// When the traces of a service run up to the limit, only those which started
+// after the oldest of them are kept.
+func (c *JaegerClient) GetTraces(ctx context.Context, length time.Duration, end time.Time) ([]Trace, time.Duration, error) {
+	services := c.services
+	if len(services) == 0 {
+		resp := struct {
+			Data []string `json:"data"`
+		}{}
+		if err := c.getJSON(ctx, "api/services", url.Values{}, &resp); err != nil {
+			return nil, 0, err
+		}
+		services = resp.Data
+	}
+
+	start, end := window(length, end)
+	cutoff := start
+	traces := []Trace{}
+	for _, service := range services {
+		query := url.Values{}
+		query.Set("service", service)
+		query.Set("start", strconv.FormatInt(start.UnixNano()/int64(time.Microsecond), 10))
+		query.Set("end", strconv.FormatInt(end.UnixNano()/int64(time.Microsecond), 10))
+		query.Set("limit", strconv.Itoa(c.limit))
+
+		resp := struct {
+			Data []jaegerTrace `json:"data"`
+		}{}
+		if err := c.getJSON(ctx, "api/traces", query, &resp); err != nil {
+			return nil, 0, err
+		}
+
+		serviceTraces := make([]Trace, 0, len(resp.Data))
+		for _, jt := range resp.Data {
+			serviceTraces = append(serviceTraces, jt.trace())
+		}
+		cutoff = limitCutoff(cutoff, serviceTraces, c.limit)
+		traces = append(traces, serviceTraces...)
+	}
+
+	return since(dedupe(traces), cutoff, end)
+}
+
+// trace converts a Jaeger trace, whose spans name their process rather than
+// their service and their parent among their references
+func (jt jaegerTrace) trace() Trace {
+	trace := make(Trace, 0, len(jt.Spans))
+	for _, js := range jt.Spans {
+		span := Span{
+			TraceID:      js.TraceID,
+			SpanID:       js.SpanID,
+			Service:      jt.Processes[js.ProcessID].ServiceName,
+			Name:         js.OperationName,
+			Start:        time.Unix(0, js.StartTime*int64(time.Microsecond)),
+			Duration:     time.Duration(js.Duration) * time.Microsecond,
+			ResponseSize: -1,
+		}
+		for _, tag := range js.Tags {
+			if !responseSizeKeys[tag.Key] {
+				continue
+			}
+			// Jaeger keeps the type of tags, so sizes may come as numbers
+			// or as strings
+			value := strings.Trim(string(tag.Value), `"`)
+			if size, ok := parseSize(value); ok {
+				span.ResponseSize = size
+			}
+		}
+		for _, ref := range js.References {
+			if ref.RefType == "CHILD_OF" || span.ParentID == "" {
+				span.ParentID = ref.SpanID
+			}
+		}
+		trace = append(trace, span)
+	}
+
+	return trace
+}
@@ -0,0 +1,186 @@
+package traces
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPFiles reads traces from files of OTLP trace data in its JSON encoding,
+// as written by the file exporter of the OpenTelemetry collector. A file may
+// hold a single export or one export per line.
+type OTLPFiles struct {
+	paths []string
+}
+
+// NewOTLPFiles is a constructor for type OTLPFiles
+func NewOTLPFiles(paths []string) *OTLPFiles {
+	return &OTLPFiles{paths: paths}
+}
+
+// otlpUint64 is an unsigned 64 bit integer, which the JSON encoding of OTLP
+// writes as a string but which is accepted as a number too
+type otlpUint64 uint64
+
+func (n *otlpUint64) UnmarshalJSON(bs []byte) error {
+	var s string
+	if err := json.Unmarshal(bs, &s); err != nil {
+		s = string(bs)
+	}
+
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*n = otlpUint64(v)
+	return nil
+}
+
+// otlpAttribute is a key and value pair of a resource or a span. Integer
+// values are written as strings, but are accepted as numbers too.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string          `json:"stringValue"`
+		IntValue    json.RawMessage `json:"intValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId"`
+	Name              string          `json:"name"`
+	StartTimeUnixNano otlpUint64      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   otlpUint64      `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+
+	// Older exporters name scope spans after instrumentation libraries
+	InstrumentationLibrarySpans []otlpScopeSpans `json:"instrumentationLibrarySpans"`
+}
+
+type otlpExport struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// GetTraces reads the traces in the files which started within the window.
+// With a zero end the window is left out and every trace is read, as files
+// are usually exported well before they are read. Either way the traces cover
+// the time from the start of their earliest span to the end of their latest.
+func (f *OTLPFiles) GetTraces(ctx context.Context, length time.Duration, end time.Time) ([]Trace, time.Duration, error) {
+	byID := make(map[string]Trace)
+	order := []string{}
+
+	for _, path := range f.paths {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		spans, err := readOTLPFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, span := range spans {
+			if _, ok := byID[span.TraceID]; !ok {
+				order = append(order, span.TraceID)
+			}
+			byID[span.TraceID] = append(byID[span.TraceID], span)
+		}
+	}
+
+	start, _ := window(length, end)
+	traces := []Trace{}
+	for _, id := range order {
+		trace := byID[id]
+		if !end.IsZero() && !startsWithin(trace, start, end) {
+			continue
+		}
+		traces = append(traces, trace)
+	}
+
+	return traces, Observed(traces), nil
+}
+
+// readOTLPFile reads the spans of every export in a file
+func readOTLPFile(path string) ([]Span, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	spans := []Span{}
+	dec := json.NewDecoder(file)
+	for {
+		export := otlpExport{}
+		err := dec.Decode(&export)
+		if err == io.EOF {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rs := range export.ResourceSpans {
+			service := ""
+			for _, attr := range rs.Resource.Attributes {
+				if attr.Key == "service.name" {
+					service = attr.Value.StringValue
+				}
+			}
+
+			for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+				for _, s := range ss.Spans {
+					start := time.Unix(0, int64(s.StartTimeUnixNano))
+					span := Span{
+						TraceID:      s.TraceID,
+						SpanID:       s.SpanID,
+						ParentID:     s.ParentSpanID,
+						Service:      service,
+						Name:         s.Name,
+						Start:        start,
+						Duration:     time.Unix(0, int64(s.EndTimeUnixNano)).Sub(start),
+						ResponseSize: -1,
+					}
+					for _, attr := range s.Attributes {
+						if !responseSizeKeys[attr.Key] {
+							continue
+						}
+						value := attr.Value.StringValue
+						if len(attr.Value.IntValue) > 0 {
+							value = strings.Trim(string(attr.Value.IntValue), `"`)
+						}
+						if size, ok := parseSize(value); ok {
+							span.ResponseSize = size
+						}
+					}
+					spans = append(spans, span)
+				}
+			}
+		}
+	}
+}
+
+// startsWithin reports whether the earliest span of a trace started within
+// the window
+func startsWithin(trace Trace, start, end time.Time) bool {
+	first := traceStart(trace)
+	return !first.Before(start) && !first.After(end)
+}
@@ -0,0 +1,214 @@
+package traces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Defaults for querying trace backends
+const (
+	defaultLimit          = 100
+	defaultRequestTimeout = 30 * time.Second
+	maxResponseSize       = 64 << 20
+)
+
+// Span is a single operation of a trace, in the service that ran it. ParentID
+// is empty for the root span of a trace. ResponseSize is the size in bytes of
+// the body of the response the span sent, or -1 if the span did not record it.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentID     string
+	Service      string
+	Name         string
+	Start        time.Time
+	Duration     time.Duration
+	ResponseSize int64
+}
+
+// responseSizeKeys are the attributes spans record the size of the body of
+// their response under, by the old and the current OpenTelemetry conventions
+var responseSizeKeys = map[string]bool{
+	"http.response_content_length": true,
+	"http.response.body.size":      true,
+}
+
+// parseSize parses a response size attribute, reporting whether it is one
+func parseSize(value string) (int64, bool) {
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+
+	return size, true
+}
+
+// Trace holds the spans of a single request through an application
+type Trace []Span
+
+// Reader is anything traces can be read from. Traces are those which started
+// in the window leading up to end, or up to now if end is zero, where the
+// reader can tell. Along with them comes the time they cover in full, which
+// rates are to be taken over: the window, unless a read hit its limit and the
+// older traces were left out.
+type Reader interface {
+	GetTraces(ctx context.Context, window time.Duration, end time.Time) ([]Trace, time.Duration, error)
+}
+
+// httpReader holds what the clients of trace query APIs share
+type httpReader struct {
+	httpClient *http.Client
+	scheme     string
+	host       string
+	services   []string
+	limit      int
+}
+
+func newHTTPReader(scheme, host string, port int, hc *http.Client, services []string, limit int) httpReader {
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultRequestTimeout}
+	}
+
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	return httpReader{
+		httpClient: hc,
+		scheme:     scheme,
+		host:       fmt.Sprintf("%s:%d", host, port),
+		services:   services,
+		limit:      limit,
+	}
+}
+
+// getJSON sends a GET request to an endpoint and decodes its response into v
+func (r httpReader) getJSON(ctx context.Context, endpoint string, query url.Values, v interface{}) error {
+	u := url.URL{
+		Scheme:   r.scheme,
+		Host:     r.host,
+		Path:     endpoint,
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(body) > 256 {
+			body = body[:256]
+		}
+		return fmt.Errorf("request to %s failed with status %d: %s", u.String(), resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("could not decode response from %s: %w", u.String(), err)
+	}
+
+	return nil
+}
+
+// window returns the start and end of a window leading up to end, or up to
+// now if end is zero
+func window(length time.Duration, end time.Time) (time.Time, time.Time) {
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	return end.Add(-length), end
+}
+
+// traceStart returns when the earliest span of a trace started
+func traceStart(trace Trace) time.Time {
+	first := trace[0].Start
+	for _, span := range trace[1:] {
+		if span.Start.Before(first) {
+			first = span.Start
+		}
+	}
+
+	return first
+}
+
+// limitCutoff moves cutoff up to the start of the oldest of traces if they
+// were read up to the limit. Backends return the latest traces first, so a
+// read hitting the limit only holds every trace which started since then.
+func limitCutoff(cutoff time.Time, traces []Trace, limit int) time.Time {
+	if len(traces) < limit {
+		return cutoff
+	}
+
+	var oldest time.Time
+	for _, trace := range traces {
+		if len(trace) == 0 {
+			continue
+		}
+		if start := traceStart(trace); oldest.IsZero() || start.Before(oldest) {
+			oldest = start
+		}
+	}
+
+	if oldest.After(cutoff) {
+		return oldest
+	}
+	return cutoff
+}
+
+// since returns the traces which started from cutoff on, along with the time
+// from cutoff to end they cover in full
+func since(traces []Trace, cutoff, end time.Time) ([]Trace, time.Duration, error) {
+	covered := end.Sub(cutoff)
+	if covered <= 0 {
+		return nil, 0, fmt.Errorf("traces read up to the limit cover no time, the limit is too low")
+	}
+
+	kept := []Trace{}
+	for _, trace := range traces {
+		if !traceStart(trace).Before(cutoff) {
+			kept = append(kept, trace)
+		}
+	}
+
+	return kept, covered, nil
+}
+
+// dedupe drops every trace but the first with the same ID. Traces are fetched
+// service by service, so a trace crossing several services comes up several
+// times.
+func dedupe(traces []Trace) []Trace {
+	seen := make(map[string]bool)
+	unique := []Trace{}
+	for _, trace := range traces {
+		if len(trace) == 0 || seen[trace[0].TraceID] {
+			continue
+		}
+		seen[trace[0].TraceID] = true
+		unique = append(unique, trace)
+	}
+
+	return unique
+}
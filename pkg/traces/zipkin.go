@@ -0,0 +1,115 @@
+package traces
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ZipkinClient reads traces from the Zipkin v2 API
+type ZipkinClient struct {
+	httpReader
+}
+
+// NewZipkinClient is a constructor for type ZipkinClient. Traces are fetched
+// for each of services, or for every service Zipkin knows if it is empty, at
+// most limit per service (100 when 0). The scheme defaults to "http" when
+// empty, and if hc is nil an http client with a 30 second timeout is used.
+func NewZipkinClient(scheme, host string, port int, hc *http.Client, services []string, limit int) *ZipkinClient {
+	return &ZipkinClient{newHTTPReader(scheme, host, port, hc, services, limit)}
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	Shared        bool              `json:"shared"`
+	Tags          map[string]string `json:"tags"`
+	LocalEndpoint struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"localEndpoint"`
+}
+
+// GetTraces fetches the traces which started within the window from Zipkin.
+// When the traces of a service run up to the limit, only those which started
+// after the oldest of them are kept.
+func (c *ZipkinClient) GetTraces(ctx context.Context, length time.Duration, end time.Time) ([]Trace, time.Duration, error) {
+	services := c.services
+	if len(services) == 0 {
+		if err := c.getJSON(ctx, "api/v2/services", url.Values{}, &services); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	start, end := window(length, end)
+	cutoff := start
+	traces := []Trace{}
+	for _, service := range services {
+		query := url.Values{}
+		query.Set("serviceName", service)
+		query.Set("endTs", strconv.FormatInt(end.UnixNano()/int64(time.Millisecond), 10))
+		query.Set("lookback", strconv.FormatInt(int64(length/time.Millisecond), 10))
+		query.Set("limit", strconv.Itoa(c.limit))
+
+		resp := [][]zipkinSpan{}
+		if err := c.getJSON(ctx, "api/v2/traces", query, &resp); err != nil {
+			return nil, 0, err
+		}
+
+		serviceTraces := make([]Trace, 0, len(resp))
+		for _, spans := range resp {
+			serviceTraces = append(serviceTraces, zipkinTrace(spans))
+		}
+		cutoff = limitCutoff(cutoff, serviceTraces, c.limit)
+		traces = append(traces, serviceTraces...)
+	}
+
+	return since(dedupe(traces), cutoff, end)
+}
+
+// zipkinTrace converts the spans of a Zipkin trace. A server span may share
+// its ID with the client span that called it, in which case the client span
+// is renamed, so that the server span is the parent of the spans it started
+// and the client span is its parent.
+func zipkinTrace(spans []zipkinSpan) Trace {
+	shared := make(map[string]bool)
+	for _, zs := range spans {
+		if zs.Shared {
+			shared[zs.ID] = true
+		}
+	}
+
+	trace := make(Trace, 0, len(spans))
+	for _, zs := range spans {
+		span := Span{
+			TraceID:      zs.TraceID,
+			SpanID:       zs.ID,
+			ParentID:     zs.ParentID,
+			Service:      zs.LocalEndpoint.ServiceName,
+			Name:         zs.Name,
+			Start:        time.Unix(0, zs.Timestamp*int64(time.Microsecond)),
+			Duration:     time.Duration(zs.Duration) * time.Microsecond,
+			ResponseSize: -1,
+		}
+		for key, value := range zs.Tags {
+			if size, ok := parseSize(value); ok && responseSizeKeys[key] {
+				span.ResponseSize = size
+			}
+		}
+
+		switch {
+		case zs.Shared:
+			span.ParentID = zs.ID + "/client"
+		case shared[zs.ID]:
+			span.SpanID = zs.ID + "/client"
+		}
+		trace = append(trace, span)
+	}
+
+	return trace
+}
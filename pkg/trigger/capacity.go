@@ -0,0 +1,325 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/k8s"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// budget maps resource names to an amount of that resource. CPU is held in
+// cores, memory in bytes and pods as a plain count.
+type budget map[apiv1.ResourceName]float64
+
+func newBudget(rl apiv1.ResourceList) budget {
+	b := make(budget)
+	for name, quantity := range rl {
+		b[name] = quantity.AsApproximateFloat64()
+	}
+
+	return b
+}
+
+// podCost returns the budget taken up by a single pod with the given requests
+// and limits. Requests are counted both under their plain name, as tracked by
+// nodes, and under their "requests." name, as tracked by quotas.
+func podCost(requests, limits apiv1.ResourceList) budget {
+	cost := make(budget)
+	for name, quantity := range requests {
+		cost[name] = quantity.AsApproximateFloat64()
+		cost[apiv1.DefaultResourceRequestsPrefix+name] = quantity.AsApproximateFloat64()
+	}
+	for name, quantity := range limits {
+		cost[apiv1.ResourceName("limits.")+name] = quantity.AsApproximateFloat64()
+	}
+
+	cost[apiv1.ResourcePods] = 1
+	cost[apiv1.ResourceName("count/pods")] = 1
+	return cost
+}
+
+// sub returns a new budget with every amount in o taken away from b.
+func (b budget) sub(o budget) budget {
+	res := make(budget)
+	for name, amount := range b {
+		res[name] = amount - o[name]
+	}
+
+	return res
+}
+
+// maxPods returns how many pods of the given cost fit in the budget along with
+// the resource that runs out first. Resources which the budget does not track
+// are treated as unlimited.
+func (b budget) maxPods(cost budget) (int, apiv1.ResourceName) {
+	n := math.MaxInt32
+	limitedBy := apiv1.ResourceName("")
+	for name, amount := range cost {
+		available, ok := b[name]
+		if !ok || amount <= 0 {
+			continue
+		}
+
+		fit := int(math.Floor(available / amount))
+		if fit < 0 {
+			fit = 0
+		}
+		if fit < n {
+			n = fit
+			limitedBy = name
+		}
+	}
+
+	return n, limitedBy
+}
+
+// take removes the cost of n pods from the budget.
+func (b budget) take(cost budget, n int) {
+	for name, amount := range cost {
+		if _, ok := b[name]; ok {
+			b[name] -= amount * float64(n)
+		}
+	}
+}
+
+// byPriority returns the deployments of a plan ordered by their configured
+// priority, highest first. Ties are broken by name to keep plans stable.
+func (tc *Client) byPriority(replicaCounts map[string]int) []string {
+	deps := make([]string, 0, len(replicaCounts))
+	for dep := range replicaCounts {
+		deps = append(deps, dep)
+	}
+
+	priorities := tc.thresholds.Priorities
+	sort.Slice(deps, func(i, j int) bool {
+		if priorities[deps[i]] != priorities[deps[j]] {
+			return priorities[deps[i]] > priorities[deps[j]]
+		}
+		return deps[i] < deps[j]
+	})
+
+	return deps
+}
+
+// fitPlan trims the replica counts of a plan so that the extra pods it asks
+// for fit within avail. Deployments are served in order of priority, each one
+// getting as many of its extra replicas as still fit. It returns the trimmed
+// plan along with the deployments that did not get everything they asked for,
+// mapped to the resource they ran short on.
+func (tc *Client) fitPlan(oldReplicaCounts, replicaCounts map[string]int, costs map[string]budget, avail budget) (map[string]int, map[string]apiv1.ResourceName) {
+	// Work on a copy so that avail is left untouched
+	remaining := avail.sub(nil)
+	fitted := make(map[string]int)
+	capped := make(map[string]apiv1.ResourceName)
+
+	for _, dep := range tc.byPriority(replicaCounts) {
+		fitted[dep] = replicaCounts[dep]
+
+		extra := replicaCounts[dep] - oldReplicaCounts[dep]
+		if extra <= 0 {
+			continue
+		}
+
+		granted := extra
+		n, limitedBy := remaining.maxPods(costs[dep])
+		if n < granted {
+			granted = n
+			capped[dep] = limitedBy
+		}
+
+		remaining.take(costs[dep], granted)
+		fitted[dep] = oldReplicaCounts[dep] + granted
+	}
+
+	return fitted, capped
+}
+
+// fitPlanToNodes trims the replica counts of a plan so that every extra pod it
+// asks for fits on one of the nodes, each with its own free budget. Pods are
+// placed first fit, on the first node by name with room for them, and
+// deployments are served in order of priority. It returns the trimmed plan
+// along with the deployments that did not get everything they asked for,
+// mapped to the resource the nodes ran short on.
+func (tc *Client) fitPlanToNodes(oldReplicaCounts, replicaCounts map[string]int, costs map[string]budget, nodes map[string]budget) (map[string]int, map[string]apiv1.ResourceName) {
+	names := make([]string, 0, len(nodes))
+	remaining := make(map[string]budget)
+	for name, free := range nodes {
+		names = append(names, name)
+		// Work on copies so that nodes are left untouched
+		remaining[name] = free.sub(nil)
+	}
+	sort.Strings(names)
+
+	fitted := make(map[string]int)
+	capped := make(map[string]apiv1.ResourceName)
+
+	for _, dep := range tc.byPriority(replicaCounts) {
+		fitted[dep] = replicaCounts[dep]
+
+		extra := replicaCounts[dep] - oldReplicaCounts[dep]
+		if extra <= 0 {
+			continue
+		}
+
+		granted := 0
+		for granted < extra {
+			ok, limitedBy := firstFit(remaining, names, costs[dep])
+			if !ok {
+				capped[dep] = limitedBy
+				break
+			}
+			granted++
+		}
+
+		fitted[dep] = oldReplicaCounts[dep] + granted
+	}
+
+	return fitted, capped
+}
+
+// firstFit places a pod of the given cost on the first of the named nodes with
+// room for it, taking its cost from that node. When no node has room, it
+// reports the resource the first node ran short on.
+func firstFit(nodes map[string]budget, names []string, cost budget) (bool, apiv1.ResourceName) {
+	limitedBy := apiv1.ResourcePods
+	for i, name := range names {
+		n, resourceName := nodes[name].maxPods(cost)
+		if n >= 1 {
+			nodes[name].take(cost, 1)
+			return true, ""
+		}
+		if i == 0 {
+			limitedBy = resourceName
+		}
+	}
+
+	return false, limitedBy
+}
+
+// getPodCosts returns the per pod cost of every node a plan adds replicas to.
+// Nodes backed by several deployments are costed by the most any of their pods
+// take of every resource. Nodes no deployment backs are logged and left out.
+func (tc *Client) getPodCosts(ctx context.Context, oldReplicaCounts, replicaCounts map[string]int) (map[string]budget, error) {
+	costs := make(map[string]budget)
+	for node, replicaCount := range replicaCounts {
+		if replicaCount <= oldReplicaCounts[node] {
+			continue
+		}
+
+		deps := tc.getBackingDeployments(node)
+		if len(deps) == 0 {
+			log.Printf("[pod cost: %s] no deployments back node, not costed\n", node)
+			continue
+		}
+
+		cost := make(budget)
+		for _, dep := range deps {
+			requests, limits, err := tc.K8sClient.GetPodTemplateResources(ctx, applicationNamespace, dep)
+			if err != nil {
+				return nil, err
+			}
+
+			for name, amount := range podCost(requests, limits) {
+				if amount > cost[name] {
+					cost[name] = amount
+				}
+			}
+		}
+		costs[node] = cost
+	}
+
+	return costs, nil
+}
+
+// fitToClusterCapacity checks whether the nodes of the cluster have room for
+// the pods a plan adds, and falls back to a partial plan served by priority
+// when they do not. Every pod has to fit on a single node. Pods still pending
+// from earlier cycles are reported and placed first, on the first node with
+// room for them. The deployments capped are reported along with why.
+func (tc *Client) fitToClusterCapacity(ctx context.Context, oldReplicaCounts, replicaCounts map[string]int, costs map[string]budget) (map[string]int, map[string]string, error) {
+	reasons := make(map[string]string)
+	free, err := tc.K8sClient.GetFreeResources(ctx)
+	if err != nil {
+		return replicaCounts, reasons, err
+	}
+
+	pending, err := tc.K8sClient.GetPendingPods(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return replicaCounts, reasons, err
+	}
+
+	nodes := make(map[string]budget)
+	names := make([]string, 0, len(free))
+	for name, rl := range free {
+		nodes[name] = newBudget(rl)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, pod := range pending {
+		firstFit(nodes, names, podCost(k8s.PodRequests(pod.Spec), nil))
+		if pod.Namespace == applicationNamespace {
+			log.Printf("[pending pod] %s has not been scheduled since %v\n", pod.Name, pod.CreationTimestamp.Time)
+		}
+	}
+
+	fitted, capped := tc.fitPlanToNodes(oldReplicaCounts, replicaCounts, costs, nodes)
+	for dep, resourceName := range capped {
+		log.Printf(
+			"[capped by cluster capacity: %s] wanted replica count: %d, granted replica count: %d, short on: %s\n",
+			dep,
+			replicaCounts[dep],
+			fitted[dep],
+			resourceName,
+		)
+		reasons[dep] = capReason("cluster capacity", replicaCounts[dep], resourceName)
+	}
+
+	return fitted, reasons, nil
+}
+
+// capReason describes why a deployment got fewer replicas than it wanted
+func capReason(cappedBy string, wanted int, shortOn apiv1.ResourceName) string {
+	return fmt.Sprintf("capped by %s: wanted %d, short on %s", cappedBy, wanted, shortOn)
+}
+
+// boundPlan holds back the nodes of a plan vetoed by their health, then trims
+// the plan to the remaining quota of the application namespace and to the free
+// capacity of the cluster. Along with the plan, it returns why every node
+// vetoed or trimmed was held back. Failing checks are logged and skipped so
+// that scaling still goes ahead.
+func (tc *Client) boundPlan(ctx context.Context, oldReplicaCounts, replicaCounts map[string]int) (map[string]int, map[string]string) {
+	replicaCounts, caps := tc.vetoUnhealthy(ctx, oldReplicaCounts, replicaCounts)
+
+	costs, err := tc.getPodCosts(ctx, oldReplicaCounts, replicaCounts)
+	if err != nil {
+		log.Println("error getting pod resources:", err)
+		return replicaCounts, caps
+	}
+
+	replicaCounts, quotaCaps, err := tc.fitToQuota(ctx, oldReplicaCounts, replicaCounts, costs)
+	if err != nil {
+		log.Println("error checking resource quota:", err)
+	}
+
+	replicaCounts, capacityCaps, err := tc.fitToClusterCapacity(ctx, oldReplicaCounts, replicaCounts, costs)
+	if err != nil {
+		log.Println("error checking cluster capacity:", err)
+	}
+
+	for _, reasons := range []map[string]string{quotaCaps, capacityCaps} {
+		for node, reason := range reasons {
+			if caps[node] != "" {
+				reason = caps[node] + "; " + reason
+			}
+			caps[node] = reason
+		}
+	}
+
+	return replicaCounts, caps
+}
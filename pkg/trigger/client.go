@@ -1,18 +1,53 @@
 package trigger
 
 import (
+	"sync"
+	"time"
+
 	"github.com/Gituser143/stunning-octo-enigma/pkg/k8s"
 	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
 	"github.com/Gituser143/stunning-octo-enigma/pkg/metricscraper"
 )
 
 // Client encapsulates a kiali client, metrics server client and a k8s
-// client. It is used to trigger scaling for an application
+// client. It is used to trigger scaling for an application. The dependency
+// graph is taken from Source, or from kiali if Source is nil.
 type Client struct {
 	KialiClient  *kiali.Client
+	Source       DependencySource
 	MetricClient *metricscraper.Client
 	K8sClient    *k8s.Client
 	thresholds   Thresholds
+	pid          *pidController
+	pidEpoch     int
+
+	scheduleMu sync.Mutex
+	schedule   []LoadStep
+
+	eventsMu  sync.Mutex
+	events    []ScalingEvent
+	decisions map[string]string
+	plan      Plan
+	plans     int
+
+	// Deployments backing each node of the graph
+	nodesMu sync.Mutex
+	nodes   map[string][]string
+
+	// Last graph seen over every duration, and how many times the topology
+	// changed
+	topologyMu    sync.Mutex
+	lastGraphs    map[time.Duration]kiali.Graph
+	topologyEpoch int
+
+	// Graphs shared between the trigger and other callers
+	graphsOnce sync.Once
+	graphs     *kiali.GraphCache
+
+	// Notices that the dependency source has no health API or cannot measure
+	// the queue lengths of workloads
+	noHealthOnce      sync.Once
+	noQueueLengthOnce sync.Once
 }
 
 // SetThresholds sets the thresholds for a given trigger client
@@ -22,3 +57,20 @@ func (tc *Client) SetThresholds(thresholds Thresholds) {
 	}
 	tc.thresholds = thresholds
 }
+
+// ScalingEvents returns all scaling actions taken by the trigger so far
+func (tc *Client) ScalingEvents() []ScalingEvent {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	events := make([]ScalingEvent, len(tc.events))
+	copy(events, tc.events)
+	return events
+}
+
+func (tc *Client) recordScalingEvent(event ScalingEvent) {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	tc.events = append(tc.events, event)
+}
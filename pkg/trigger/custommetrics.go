@@ -0,0 +1,89 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/metricscraper"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// getCustomMetrics returns the current value of every custom and external
+// metric a threshold is set on, by deployment and metric name. Metrics which
+// cannot be read are logged and left out, so that scaling goes ahead on the
+// others.
+func (tc *Client) getCustomMetrics(ctx context.Context, depSelectors map[string]labels.Selector) map[string]map[string]float64 {
+	values := make(map[string]map[string]float64)
+
+	for dep, metricThresholds := range tc.thresholds.MetricThresholds {
+		for _, threshold := range metricThresholds {
+			value, err := tc.getMetricValue(ctx, dep, depSelectors[dep], threshold)
+			if err != nil {
+				log.Printf("[metric: %s] error getting %s: %s\n", dep, threshold.Metric, err)
+				continue
+			}
+
+			if _, ok := values[dep]; !ok {
+				values[dep] = make(map[string]float64)
+			}
+			values[dep][threshold.Metric] = value
+		}
+	}
+
+	return values
+}
+
+// getMetricValue reads the metric of a threshold for a deployment whose pods
+// are matched by selector, averaged over its pods, off the deployment itself
+// or summed over its series from outside the cluster depending on the type of
+// the threshold.
+func (tc *Client) getMetricValue(ctx context.Context, dep string, selector labels.Selector, threshold MetricThreshold) (float64, error) {
+	metricSelector := labels.SelectorFromSet(threshold.Selector)
+
+	switch threshold.Type {
+	case "", MetricTypePods:
+		if selector == nil {
+			return 0, fmt.Errorf("no selector known for the pods of deployment %s", dep)
+		}
+
+		values, err := tc.MetricClient.GetPodsCustomMetric(ctx, applicationNamespace, selector, threshold.Metric, metricSelector)
+		if err != nil {
+			return 0, err
+		}
+		if len(values) == 0 {
+			return 0, fmt.Errorf("no pods of deployment %s report %s", dep, threshold.Metric)
+		}
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v.Value.AsApproximateFloat64()
+		}
+		return sum / float64(len(values)), nil
+
+	case MetricTypeObject:
+		value, err := tc.MetricClient.GetObjectCustomMetric(ctx, applicationNamespace, metricscraper.DeploymentKind, dep, threshold.Metric, metricSelector)
+		if err != nil {
+			return 0, err
+		}
+		return value.Value.AsApproximateFloat64(), nil
+
+	case MetricTypeExternal:
+		values, err := tc.MetricClient.GetExternalMetric(ctx, applicationNamespace, threshold.Metric, metricSelector)
+		if err != nil {
+			return 0, err
+		}
+		if len(values) == 0 {
+			return 0, fmt.Errorf("no series of external metric %s found", threshold.Metric)
+		}
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v.Value.AsApproximateFloat64()
+		}
+		return sum, nil
+
+	default:
+		return 0, fmt.Errorf("unknown metric type %q", threshold.Type)
+	}
+}
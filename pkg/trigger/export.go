@@ -0,0 +1,174 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
+
+// recordDecisions keeps what a scaling cycle decided for every node of its
+// plan, and why it was capped if it was, to annotate exported graphs with
+func (tc *Client) recordDecisions(oldReplicaCounts, replicaCounts map[string]int, caps map[string]string, origin string) {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	if tc.decisions == nil {
+		tc.decisions = make(map[string]string)
+	}
+
+	for service, replicaCount := range replicaCounts {
+		if replicaCount > oldReplicaCounts[service] {
+			tc.decisions[service] = fmt.Sprintf("%s: %d -> %d", origin, oldReplicaCounts[service], replicaCount)
+		} else {
+			tc.decisions[service] = fmt.Sprintf("%s: held at %d", origin, oldReplicaCounts[service])
+		}
+		if reason, ok := caps[service]; ok {
+			tc.decisions[service] += " (" + reason + ")"
+		}
+	}
+}
+
+// Plan is a scaling plan as it was applied, along with the graph it was
+// decided on
+type Plan struct {
+	Time        time.Time
+	Origin      string
+	Graph       kiali.Graph
+	Annotations kiali.Annotations
+}
+
+// recordPlan keeps the plan just applied to render it later. Nodes are
+// annotated with their replicas and queue lengths as they were when the plan
+// was decided, queue lengths as read off the graph, and with the decisions
+// recorded for them. CPU usage is not part of every plan and is left out.
+func (tc *Client) recordPlan(kialiGraph kiali.Graph, oldReplicaCounts map[string]int, origin string) {
+	queueLengths, _ := kialiGraph.GetQueueLengths()
+	queueLengthThresholds := tc.getQueueLengthThresholds("queue.json")
+
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	annotations := make(kiali.Annotations)
+	for service, replicaCount := range oldReplicaCounts {
+		annotations[service] = kiali.NodeAnnotation{
+			Replicas:             replicaCount,
+			QueueLength:          queueLengths[service],
+			QueueLengthThreshold: queueLengthThresholds[service],
+			Decision:             tc.decisions[service],
+		}
+	}
+
+	tc.plan = Plan{
+		Time:        time.Now(),
+		Origin:      origin,
+		Graph:       kialiGraph,
+		Annotations: annotations,
+	}
+	tc.plans++
+}
+
+// LastPlan returns the last plan which scaled any node, along with how many
+// such plans were applied so far. No plan was applied while that is 0.
+func (tc *Client) LastPlan() (Plan, int) {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	return tc.plan, tc.plans
+}
+
+// Decisions returns what the last scaling cycles decided for every node they
+// planned for, by node name
+func (tc *Client) Decisions() map[string]string {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	decisions := make(map[string]string)
+	for service, decision := range tc.decisions {
+		decisions[service] = decision
+	}
+
+	return decisions
+}
+
+// SetDecisions sets the decisions exported graphs are annotated with, e.g.
+// those saved by a trigger running in another process
+func (tc *Client) SetDecisions(decisions map[string]string) {
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	tc.decisions = make(map[string]string)
+	for service, decision := range decisions {
+		tc.decisions[service] = decision
+	}
+}
+
+// GetGraph returns the live graph of the application, of the configured type
+func (tc *Client) GetGraph(ctx context.Context) (kiali.Graph, error) {
+	return tc.getGraph(ctx, time.Minute)
+}
+
+// GetAnnotations returns the annotations of every node of a graph: its
+// replicas, CPU usage and queue length against their thresholds and what the
+// last scaling cycle decided for it. The CPU usage of a node is that of the
+// first deployment backing it which has a threshold.
+func (tc *Client) GetAnnotations(ctx context.Context, kialiGraph kiali.Graph) (kiali.Annotations, error) {
+	replicaCounts, err := tc.getReplicaCounts(ctx, kialiGraph)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := tc.K8sClient.GetDeployments(ctx, applicationNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	depMetrics := tc.getDeploymentMetrics(ctx, deploymentSelectors(deployments))
+
+	queueLengths := tc.getQueueLengths(ctx, kialiGraph)
+	queueLengthThresholds := tc.getQueueLengthThresholds("queue.json")
+
+	tc.eventsMu.Lock()
+	defer tc.eventsMu.Unlock()
+
+	annotations := make(kiali.Annotations)
+	for service, replicaCount := range replicaCounts {
+		a := kiali.NodeAnnotation{
+			Replicas:             replicaCount,
+			QueueLength:          queueLengths[service],
+			QueueLengthThreshold: queueLengthThresholds[service],
+			Decision:             tc.decisions[service],
+		}
+
+		for _, dep := range tc.getBackingDeployments(service) {
+			if threshold, ok := tc.thresholds.ResourceThresholds[dep]; ok && threshold.CPU > 0 {
+				a.CPU = depMetrics[dep].CPU
+				a.CPUThreshold = threshold.CPU
+				break
+			}
+		}
+
+		annotations[service] = a
+	}
+
+	return annotations, nil
+}
+
+// ExportGraph writes the live graph of the application annotated with what the
+// trigger knows about its nodes, in one of the "dot", "mermaid" or "json"
+// formats
+func (tc *Client) ExportGraph(ctx context.Context, w io.Writer, format string) error {
+	kialiGraph, err := tc.GetGraph(ctx)
+	if err != nil {
+		return err
+	}
+
+	annotations, err := tc.GetAnnotations(ctx, kialiGraph)
+	if err != nil {
+		return err
+	}
+
+	return kialiGraph.Export(w, format, annotations)
+}
@@ -0,0 +1,159 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
+
+// defaultStartupLatency is used when no pod startup latency is configured
+const defaultStartupLatency = 30 * time.Second
+
+// SetLoadSchedule hands the trigger the upcoming load steps of the
+// application, replacing any schedule handed earlier.
+func (tc *Client) SetLoadSchedule(schedule []LoadStep) {
+	tc.scheduleMu.Lock()
+	defer tc.scheduleMu.Unlock()
+
+	tc.schedule = schedule
+}
+
+// getNextLoadStep returns the first step of the schedule which starts after
+// now but no later than lead from now.
+func (tc *Client) getNextLoadStep(now time.Time, lead time.Duration) (LoadStep, bool) {
+	tc.scheduleMu.Lock()
+	defer tc.scheduleMu.Unlock()
+
+	for _, step := range tc.schedule {
+		if step.Start.After(now) && !step.Start.After(now.Add(lead)) {
+			return step, true
+		}
+	}
+
+	return LoadStep{}, false
+}
+
+// startFeedForward pre-scales the application ahead of every step of the load
+// schedule, early enough for new pods to be ready by the time the step starts.
+func (tc *Client) startFeedForward(ctx context.Context) {
+	lead := time.Duration(tc.thresholds.FeedForward.StartupLatency) * time.Second
+	if lead <= 0 {
+		lead = defaultStartupLatency
+	}
+
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+
+	lastIndex := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-t.C:
+			step, ok := tc.getNextLoadStep(time.Now(), lead)
+			if !ok || step.Index <= lastIndex {
+				continue
+			}
+
+			lastIndex = step.Index
+			if err := tc.preScale(ctx, step); err != nil {
+				log.Println("error pre-scaling for load step:", err)
+			}
+		}
+	}
+}
+
+// preScale scales the application for the rate of an upcoming load step. The
+// services called from outside the mesh are scaled by the ratio of the step's
+// rate to the current request rate.
+func (tc *Client) preScale(ctx context.Context, step LoadStep) error {
+	kialiGraph, err := tc.getGraph(ctx, time.Minute)
+	if err != nil {
+		return err
+	}
+	tc.observeTopology(kialiGraph, time.Minute)
+
+	currentRate, err := tc.getRequestRate(kialiGraph)
+	if err != nil {
+		return err
+	}
+
+	if currentRate <= 0 || step.Rate <= currentRate {
+		log.Printf("[feedforward] step %d at %.0f req/s needs no pre-scaling, current rate: %.0f req/s\n", step.Index, step.Rate, currentRate)
+		return nil
+	}
+
+	ratios := make(map[string]float64)
+	for _, service := range tc.getEntryServices(kialiGraph) {
+		ratios[service] = step.Rate / currentRate
+	}
+
+	log.Printf("[feedforward] pre-scaling for step %d at %.0f req/s starting at %v\n", step.Index, step.Rate, step.Start)
+	return tc.preScaleForRatios(ctx, kialiGraph, ratios, originFeedForward, step.Start)
+}
+
+// preScaleForRatios scales services ahead of demand. Each service in ratios
+// has its queue length scaled by its ratio to estimate the queue length it
+// will see, and is scaled up if that crosses its threshold. The effect is then
+// propagated downstream as in reactive scaling.
+func (tc *Client) preScaleForRatios(ctx context.Context, kialiGraph kiali.Graph, ratios map[string]float64, origin string, stepStart time.Time) error {
+	oldReplicaCounts, err := tc.getReplicaCounts(ctx, kialiGraph)
+	if err != nil {
+		return err
+	}
+
+	replicaCounts := make(map[string]int)
+	for service, replicaCount := range oldReplicaCounts {
+		replicaCounts[service] = replicaCount
+	}
+
+	queueLengths := tc.getQueueLengths(ctx, kialiGraph)
+	queueLengthThresholds := tc.getQueueLengthThresholds("queue.json")
+
+	services := []string{}
+	for service, ratio := range ratios {
+		threshold := queueLengthThresholds[service]
+		if ratio <= 1 || threshold <= 0 {
+			continue
+		}
+
+		newQueueLength := queueLengths[service] * ratio
+		newReplicaCount := (int)(math.Ceil(newQueueLength/threshold)) * oldReplicaCounts[service]
+
+		log.Printf(
+			"[%s: %s] demand ratio: %f, old ql: %f, new ql: %f\n",
+			origin,
+			service,
+			ratio,
+			queueLengths[service],
+			newQueueLength,
+		)
+		if newReplicaCount > replicaCounts[service] {
+			replicaCounts[service] = newReplicaCount
+			services = append(services, service)
+		}
+	}
+
+	tc.propagateReplicaCounts(kialiGraph, queueLengths, oldReplicaCounts, replicaCounts, services)
+	replicaCounts, caps := tc.boundPlan(ctx, oldReplicaCounts, replicaCounts)
+	tc.applyReplicaCounts(ctx, kialiGraph, oldReplicaCounts, replicaCounts, caps, origin, stepStart)
+
+	return nil
+}
+
+// getEntryServices returns the services which take requests from the entry
+// points of the application.
+func (tc *Client) getEntryServices(kialiGraph kiali.Graph) []string {
+	services := []string{}
+	for _, edges := range kialiGraph.EntryEdges(kialiGraph.SelectEntries(tc.thresholds.Entry)) {
+		for _, edge := range edges {
+			services = append(services, kialiGraph[edge.Target].Name())
+		}
+	}
+
+	return services
+}
@@ -0,0 +1,144 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// getDegradedWorkloads returns the inbound request error ratio of every
+// workload of the application whose ratio is above the configured one. None
+// are degraded when the dependency source has no health API.
+func (tc *Client) getDegradedWorkloads(ctx context.Context) (map[string]float64, error) {
+	conf := tc.thresholds.Health
+	degraded := make(map[string]float64)
+	if !conf.Enabled || conf.ErrorRatio <= 0 {
+		return degraded, nil
+	}
+
+	hs, ok := tc.healthSource()
+	if !ok {
+		return degraded, nil
+	}
+
+	health, err := hs.GetWorkloadHealth(ctx, applicationNamespace, time.Duration(conf.RateInterval)*time.Second)
+	if err != nil {
+		return degraded, err
+	}
+
+	for workload, h := range health {
+		errorRatio := h.Requests.InboundErrorRatio()
+		if errorRatio > conf.ErrorRatio {
+			log.Printf("[health: %s] inbound error ratio: %.3f, above: %.3f\n", workload, errorRatio, conf.ErrorRatio)
+			degraded[workload] = errorRatio
+		}
+	}
+
+	return degraded, nil
+}
+
+// checkHealth flags the application for scaling if any of its workloads is
+// degraded in a snapshot.
+func (tc *Client) checkHealth(s *snapshot) error {
+	if len(s.degraded) > 0 {
+		return errScaleApplication
+	}
+
+	return nil
+}
+
+// scaleDegradedWorkloads raises the replica counts of the nodes backed by
+// degraded workloads in proportion to the share of requests they fail, and
+// returns those nodes.
+func (tc *Client) scaleDegradedWorkloads(degraded map[string]float64, oldReplicaCounts, replicaCounts map[string]int) []string {
+	services := []string{}
+	for workload, errorRatio := range degraded {
+		service := tc.getNodeForDeployment(workload)
+		if oldReplicaCounts[service] <= 0 {
+			continue
+		}
+
+		replicaCount := int(math.Ceil(float64(oldReplicaCounts[service]) * (1 + errorRatio)))
+		log.Printf(
+			"[health rc for %s] error ratio: %.3f, old replica count: %d, new replica count: %d\n",
+			service,
+			errorRatio,
+			oldReplicaCounts[service],
+			replicaCount,
+		)
+
+		if replicaCount > replicaCounts[service] {
+			replicaCounts[service] = replicaCount
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+// vetoUnhealthy holds back the nodes of a plan backed by a workload with less
+// of its replicas available than configured. Pods which do not become ready,
+// e.g. crash looping ones, are not helped by adding more of them. Vetoes are
+// logged and returned along with their reason, and a failure to get the health
+// of the workloads is logged and vetoes nothing, as does a dependency source
+// without a health API.
+func (tc *Client) vetoUnhealthy(ctx context.Context, oldReplicaCounts, replicaCounts map[string]int) (map[string]int, map[string]string) {
+	reasons := make(map[string]string)
+
+	conf := tc.thresholds.Health
+	if !conf.Enabled || conf.MinAvailability <= 0 {
+		return replicaCounts, reasons
+	}
+
+	hs, ok := tc.healthSource()
+	if !ok {
+		return replicaCounts, reasons
+	}
+
+	health, err := hs.GetWorkloadHealth(ctx, applicationNamespace, time.Duration(conf.RateInterval)*time.Second)
+	if err != nil {
+		log.Println("error getting workload health:", err)
+		return replicaCounts, reasons
+	}
+
+	vetoed := make(map[string]int)
+	for service, replicaCount := range replicaCounts {
+		vetoed[service] = replicaCount
+		if replicaCount <= oldReplicaCounts[service] {
+			continue
+		}
+
+		for _, dep := range tc.getBackingDeployments(service) {
+			h, ok := health[dep]
+			if !ok || h.WorkloadStatus == nil {
+				continue
+			}
+
+			status := h.WorkloadStatus
+			if status.Availability() < conf.MinAvailability {
+				log.Printf(
+					"[health veto: %s] %d of %d replicas of %s available, below %.2f, wanted replica count: %d\n",
+					service,
+					status.AvailableReplicas,
+					status.DesiredReplicas,
+					dep,
+					conf.MinAvailability,
+					replicaCount,
+				)
+				vetoed[service] = oldReplicaCounts[service]
+				reasons[service] = fmt.Sprintf(
+					"vetoed by health: wanted %d, %d of %d replicas of %s available",
+					replicaCount,
+					status.AvailableReplicas,
+					status.DesiredReplicas,
+					dep,
+				)
+				break
+			}
+		}
+	}
+
+	return vetoed, reasons
+}
@@ -0,0 +1,178 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// resolveDeployments returns the deployments backing a node of the graph,
+// among the deployments given. Workload nodes are their own deployment. App
+// nodes are backed by the deployments whose pods carry the app's "app" label,
+// and its "version" label too for nodes of a versioned app graph. Service nodes
+// are backed by the deployments whose pods the service selects, with the
+// selectors of services keyed by service name.
+func resolveDeployments(item *kiali.Item, deployments []appsv1.Deployment, serviceSelectors map[string]map[string]string) []string {
+	switch item.Type {
+	case kiali.NodeTypeWorkload:
+		if item.Node.Workload == "" {
+			return nil
+		}
+		return []string{item.Node.Workload}
+
+	case kiali.NodeTypeApp:
+		if item.Node.App == "" {
+			return nil
+		}
+
+		selector := map[string]string{"app": item.Node.App}
+		if item.Node.Version != "" {
+			selector["version"] = item.Node.Version
+		}
+		return deploymentsForSelector(deployments, selector)
+
+	case kiali.NodeTypeService:
+		// A service without a selector does not pick its pods from deployments
+		selector := serviceSelectors[item.Node.Service]
+		if len(selector) == 0 {
+			return nil
+		}
+		return deploymentsForSelector(deployments, selector)
+	}
+
+	return nil
+}
+
+// deploymentsForSelector returns the names of the deployments whose pods
+// carry every label of selector
+func deploymentsForSelector(deployments []appsv1.Deployment, selector map[string]string) []string {
+	s := labels.SelectorFromSet(selector)
+	names := []string{}
+	for _, d := range deployments {
+		if s.Matches(labels.Set(d.Spec.Template.Labels)) {
+			names = append(names, d.Name)
+		}
+	}
+
+	return names
+}
+
+// getServiceSelectors returns the pod selector of every service of the
+// application if the graph has service nodes, listing the services once
+func (tc *Client) getServiceSelectors(ctx context.Context, kialiGraph kiali.Graph) (map[string]map[string]string, error) {
+	selectors := make(map[string]map[string]string)
+
+	hasServices := false
+	for _, item := range kialiGraph {
+		if item.Type == kiali.NodeTypeService {
+			hasServices = true
+			break
+		}
+	}
+	if !hasServices {
+		return selectors, nil
+	}
+
+	services, err := tc.K8sClient.GetServices(ctx, applicationNamespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		selectors[svc.Name] = svc.Spec.Selector
+	}
+
+	return selectors, nil
+}
+
+// setBackingDeployments records the deployments backing a node
+func (tc *Client) setBackingDeployments(node string, deps []string) {
+	tc.nodesMu.Lock()
+	defer tc.nodesMu.Unlock()
+
+	if tc.nodes == nil {
+		tc.nodes = make(map[string][]string)
+	}
+	tc.nodes[node] = deps
+}
+
+// getBackingDeployments returns the deployments last seen backing a node. A
+// node that was never resolved is taken to be a deployment of the same name.
+func (tc *Client) getBackingDeployments(node string) []string {
+	tc.nodesMu.Lock()
+	defer tc.nodesMu.Unlock()
+
+	if deps, ok := tc.nodes[node]; ok && len(deps) > 0 {
+		return deps
+	}
+	return []string{node}
+}
+
+// getNodeForDeployment returns the node a deployment was last seen backing,
+// or the deployment itself if it backs none.
+func (tc *Client) getNodeForDeployment(dep string) string {
+	tc.nodesMu.Lock()
+	defer tc.nodesMu.Unlock()
+
+	for node, deps := range tc.nodes {
+		for _, d := range deps {
+			if d == dep && node != dep {
+				return node
+			}
+		}
+	}
+	return dep
+}
+
+// scaleNode scales the deployments backing a node from replicaCount replicas
+// in all up to newReplicaCount. The new replicas are spread across the
+// deployments in proportion to their current replica counts, or evenly if
+// none of them has any, rounding up.
+func (tc *Client) scaleNode(ctx context.Context, node string, replicaCount, newReplicaCount int) error {
+	deps := tc.getBackingDeployments(node)
+	if len(deps) == 1 {
+		return tc.K8sClient.ScaleDeployment(ctx, applicationNamespace, deps[0], int32(newReplicaCount))
+	}
+
+	currentReplicaCounts := make(map[string]int)
+	total := 0
+	for _, dep := range deps {
+		currentReplicaCount, err := tc.K8sClient.GetCurrentReplicaCount(ctx, applicationNamespace, dep)
+		if err != nil {
+			return err
+		}
+		currentReplicaCounts[dep] = int(currentReplicaCount)
+		total += int(currentReplicaCount)
+	}
+
+	for _, dep := range deps {
+		var depReplicaCount int
+		if total > 0 {
+			depReplicaCount = int(math.Ceil(float64(currentReplicaCounts[dep]) * float64(newReplicaCount) / float64(total)))
+		} else {
+			depReplicaCount = int(math.Ceil(float64(newReplicaCount) / float64(len(deps))))
+		}
+
+		if depReplicaCount <= currentReplicaCounts[dep] {
+			continue
+		}
+
+		log.Printf(
+			"[replicas for: %s/%s] old replica count: %d, new replica count: %d\n",
+			node,
+			dep,
+			currentReplicaCounts[dep],
+			depReplicaCount,
+		)
+
+		err := tc.K8sClient.ScaleDeployment(ctx, applicationNamespace, dep, int32(depReplicaCount))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
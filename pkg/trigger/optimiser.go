@@ -0,0 +1,186 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// headroom is how many times the current load a service can take at its
+// current replica count before hitting one of its thresholds, along with the
+// signal that threshold belongs to.
+type headroom struct {
+	factor float64
+	signal string
+}
+
+// getPodBudget returns the number of extra pods the optimiser may hand out,
+// either as configured or as the pods left in the namespace quota, which is
+// none once the namespace is over its quota. errNoPodBudget is returned when
+// neither is set.
+func (tc *Client) getPodBudget(ctx context.Context) (int, error) {
+	if tc.thresholds.Optimiser.PodBudget > 0 {
+		return tc.thresholds.Optimiser.PodBudget, nil
+	}
+
+	remaining, err := tc.K8sClient.GetRemainingQuota(ctx, applicationNamespace)
+	if err != nil {
+		return 0, err
+	}
+
+	podBudget := -1
+	quota := newBudget(remaining)
+	for _, name := range []apiv1.ResourceName{apiv1.ResourcePods, apiv1.ResourceName("count/pods")} {
+		pods, ok := quota[name]
+		if !ok {
+			continue
+		}
+		if pods < 0 {
+			pods = 0
+		}
+		if podBudget < 0 || int(pods) < podBudget {
+			podBudget = int(pods)
+		}
+	}
+
+	if podBudget < 0 {
+		return 0, errNoPodBudget
+	}
+
+	return podBudget, nil
+}
+
+// getHeadrooms estimates the capacity of every service in the graph as its
+// headroom. Queue lengths are compared against the queue length thresholds,
+// and for base deployments resource usage and custom metrics are compared
+// against their thresholds. The tightest of these wins. Services without any estimate are
+// left out and are never treated as bottlenecks.
+func (tc *Client) getHeadrooms(kialiGraph kiali.Graph, queueLengths map[string]float64, baseDeps map[string]Resources) map[string]headroom {
+	queueLengthThresholds := tc.getQueueLengthThresholds("queue.json")
+	headrooms := make(map[string]headroom)
+
+	for _, item := range kialiGraph {
+		service := item.Name()
+		if item.IsUnknown() {
+			continue
+		}
+
+		h := headroom{factor: math.Inf(1)}
+		update := func(threshold, current float64, signal string) {
+			if threshold > 0 && current > 0 && threshold/current < h.factor {
+				h = headroom{factor: threshold / current, signal: signal}
+			}
+		}
+
+		update(queueLengthThresholds[service], queueLengths[service], "queue length")
+		if metrics, ok := baseDeps[service]; ok {
+			threshold := tc.thresholds.ResourceThresholds[service]
+			update(threshold.CPU, metrics.CPU, "cpu")
+			update(threshold.Memory, metrics.Memory, "memory")
+			for _, mt := range tc.thresholds.MetricThresholds[service] {
+				update(mt.Target, metrics.Custom[mt.Metric], mt.Metric)
+			}
+		}
+
+		if !math.IsInf(h.factor, 1) {
+			headrooms[service] = h
+		}
+	}
+
+	return headrooms
+}
+
+// optimiseReplicaCounts spreads a budget of extra pods across the graph so as
+// to maximise the predicted e2e throughput. A service is predicted to cap the
+// e2e throughput at current throughput * headroom * new replicas / old
+// replicas, and the application at the lowest of these caps. Every pod goes to
+// the service holding the lowest cap at that point, until the budget runs out
+// or the predicted e2e throughput meets the configured target. Each pod handed
+// out is logged along with the reason it went where it did.
+func (tc *Client) optimiseReplicaCounts(
+	kialiGraph kiali.Graph,
+	queueLengths map[string]float64,
+	baseDeps map[string]Resources,
+	oldReplicaCounts map[string]int,
+	podBudget int,
+) map[string]int {
+	replicaCounts := make(map[string]int)
+	for service, replicaCount := range oldReplicaCounts {
+		replicaCounts[service] = replicaCount
+	}
+
+	throughput, err := tc.getE2EThroughput(kialiGraph)
+	if err != nil || throughput <= 0 {
+		log.Println("[optimiser] no e2e throughput to optimise for, not scaling")
+		return replicaCounts
+	}
+
+	headrooms := tc.getHeadrooms(kialiGraph, queueLengths, baseDeps)
+	services := []string{}
+	for service := range headrooms {
+		if oldReplicaCounts[service] > 0 {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+
+	predict := func(service string) float64 {
+		scale := float64(replicaCounts[service]) / float64(oldReplicaCounts[service])
+		return float64(throughput) * headrooms[service].factor * scale
+	}
+
+	bottleneck := func() (string, float64) {
+		minService, minThroughput := "", math.Inf(1)
+		for _, service := range services {
+			if p := predict(service); p < minThroughput {
+				minService, minThroughput = service, p
+			}
+		}
+		return minService, minThroughput
+	}
+
+	_, initial := bottleneck()
+	target := float64(tc.thresholds.Throughput)
+	spent := 0
+
+	for ; spent < podBudget; spent++ {
+		service, predicted := bottleneck()
+		if service == "" {
+			break
+		}
+
+		if target > 0 && predicted >= target {
+			log.Printf("[optimiser] predicted e2e throughput %.0f meets target %d\n", predicted, tc.thresholds.Throughput)
+			break
+		}
+
+		replicaCounts[service]++
+		_, next := bottleneck()
+		log.Printf(
+			"[optimiser: %s] replica %d given as bottleneck capping e2e throughput at %.0f (%s headroom %.2f at %d replicas), predicted e2e throughput now %.0f\n",
+			service,
+			replicaCounts[service],
+			predicted,
+			headrooms[service].signal,
+			headrooms[service].factor,
+			oldReplicaCounts[service],
+			next,
+		)
+	}
+
+	_, final := bottleneck()
+	log.Printf(
+		"[optimiser] used %d of %d pods, predicted e2e throughput %.0f -> %.0f, current e2e throughput %d\n",
+		spent,
+		podBudget,
+		initial,
+		final,
+		throughput,
+	)
+
+	return replicaCounts
+}
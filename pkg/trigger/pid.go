@@ -0,0 +1,203 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// Defaults for the PID controller, used when they are not configured
+const (
+	defaultKp            = 1.0
+	defaultIntegralLimit = 1.0
+	defaultOutputLimit   = 1.0
+)
+
+// pidController is a PID controller with clamping on both its integral term
+// and its output. While the output is clamped, the integral only accumulates
+// errors that pull the output back into range, so that it does not wind up.
+type pidController struct {
+	kp, ki, kd    float64
+	integralLimit float64
+	outputLimit   float64
+
+	integral  float64
+	lastError float64
+	lastTime  time.Time
+}
+
+func newPIDController(conf PIDConfig) *pidController {
+	c := &pidController{
+		kp:            conf.Kp,
+		ki:            conf.Ki,
+		kd:            conf.Kd,
+		integralLimit: conf.IntegralLimit,
+		outputLimit:   conf.OutputLimit,
+	}
+
+	if c.kp == 0 && c.ki == 0 && c.kd == 0 {
+		c.kp = defaultKp
+	}
+	if c.integralLimit <= 0 {
+		c.integralLimit = defaultIntegralLimit
+	}
+	if c.outputLimit <= 0 {
+		c.outputLimit = defaultOutputLimit
+	}
+
+	return c
+}
+
+// update feeds the error measured at now to the controller and returns its
+// output along with the proportional, integral and derivative terms.
+func (c *pidController) update(e float64, now time.Time) (float64, [3]float64) {
+	dt := 0.0
+	if !c.lastTime.IsZero() {
+		dt = now.Sub(c.lastTime).Seconds()
+	}
+
+	derivative := 0.0
+	if dt > 0 {
+		derivative = (e - c.lastError) / dt
+	}
+
+	integral := c.integral + e*dt
+	integral = math.Max(-c.integralLimit, math.Min(c.integralLimit, integral))
+
+	terms := [3]float64{c.kp * e, c.ki * integral, c.kd * derivative}
+	output := terms[0] + terms[1] + terms[2]
+
+	// Only integrate while unsaturated, or when the error unwinds the output
+	if math.Abs(output) <= c.outputLimit || math.Signbit(e) != math.Signbit(output) {
+		c.integral = integral
+	} else {
+		terms[1] = c.ki * c.integral
+	}
+
+	output = math.Max(-c.outputLimit, math.Min(c.outputLimit, terms[0]+terms[1]+terms[2]))
+
+	c.lastError = e
+	c.lastTime = now
+
+	return output, terms
+}
+
+// controlThroughput runs one step of closed-loop control on the e2e
+// throughput. The relative error from the throughput setpoint is turned into a
+// relative amount of capacity to add by the PID controller. That capacity is
+// spread over the bottleneck services, i.e. every service whose estimated
+// headroom caps the e2e throughput below the measured throughput grown by the
+// controller output gets enough replicas to lift its cap to it. Without queue
+// length thresholds to estimate headrooms by, or during an outage with no
+// throughput to scale them by, every service on the critical path, or every
+// entry service when there is none, is grown by the output.
+func (tc *Client) controlThroughput(ctx context.Context) error {
+	setpoint := float64(tc.thresholds.Throughput)
+	if setpoint <= 0 {
+		return nil
+	}
+
+	kialiGraph, err := tc.getGraph(ctx, time.Minute)
+	if err != nil {
+		return err
+	}
+	tc.observeTopology(kialiGraph, time.Minute)
+
+	// A controller tuned on an old topology starts over
+	if epoch := tc.getTopologyEpoch(); tc.pid == nil || tc.pidEpoch != epoch {
+		tc.pid = newPIDController(tc.thresholds.PID)
+		tc.pidEpoch = epoch
+	}
+
+	throughput, err := tc.getE2EThroughput(kialiGraph)
+	if err != nil {
+		return err
+	}
+
+	e := (setpoint - float64(throughput)) / setpoint
+	output, terms := tc.pid.update(e, time.Now())
+	log.Printf(
+		"[pid] setpoint: %d, e2e throughput: %d, error: %.3f, output: %.3f (p: %.3f, i: %.3f, d: %.3f)\n",
+		tc.thresholds.Throughput,
+		throughput,
+		e,
+		output,
+		terms[0],
+		terms[1],
+		terms[2],
+	)
+
+	if output <= 0 {
+		return nil
+	}
+
+	oldReplicaCounts, err := tc.getReplicaCounts(ctx, kialiGraph)
+	if err != nil {
+		return err
+	}
+
+	replicaCounts := make(map[string]int)
+	for service, replicaCount := range oldReplicaCounts {
+		replicaCounts[service] = replicaCount
+	}
+
+	reason := ""
+	if len(tc.getQueueLengthThresholds("queue.json")) == 0 {
+		reason = "no queue length thresholds"
+	} else if throughput <= 0 {
+		reason = "no e2e throughput"
+	}
+
+	if reason != "" {
+		// Without queue length thresholds, or without any throughput to
+		// scale them by, there are no headrooms to find the bottlenecks by,
+		// so the capacity goes to the critical path, or to the entry services
+		// when it cannot be found
+		path, _ := kialiGraph.CriticalPath(kialiGraph.SelectEntries(tc.thresholds.Entry))
+		services := nodeNames(kialiGraph, path)
+		fallback := "critical path"
+		if len(services) == 0 {
+			services = tc.getEntryServices(kialiGraph)
+			fallback = "entry services"
+		}
+		log.Printf("[pid] %s, spreading output over the %s: %s\n", reason, fallback, strings.Join(services, ", "))
+
+		for _, service := range services {
+			if oldReplicaCounts[service] <= 0 {
+				continue
+			}
+			replicaCounts[service] = int(math.Ceil(float64(oldReplicaCounts[service]) * (1 + output)))
+		}
+
+		replicaCounts, caps := tc.boundPlan(ctx, oldReplicaCounts, replicaCounts)
+		tc.applyReplicaCounts(ctx, kialiGraph, oldReplicaCounts, replicaCounts, caps, originPID, time.Time{})
+		return nil
+	}
+
+	queueLengths := tc.getQueueLengths(ctx, kialiGraph)
+	target := float64(throughput) * (1 + output)
+
+	for service, h := range tc.getHeadrooms(kialiGraph, queueLengths, nil) {
+		capped := float64(throughput) * h.factor
+		if oldReplicaCounts[service] <= 0 || capped >= target {
+			continue
+		}
+
+		replicaCounts[service] = int(math.Ceil(float64(oldReplicaCounts[service]) * target / capped))
+		log.Printf(
+			"[pid: %s] caps e2e throughput at %.0f below target %.0f (%s headroom %.2f)\n",
+			service,
+			capped,
+			target,
+			h.signal,
+			h.factor,
+		)
+	}
+
+	replicaCounts, caps := tc.boundPlan(ctx, oldReplicaCounts, replicaCounts)
+	tc.applyReplicaCounts(ctx, kialiGraph, oldReplicaCounts, replicaCounts, caps, originPID, time.Time{})
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/forecast"
+)
+
+// Defaults for predictive scaling, used when they are not configured
+const (
+	defaultPredictiveInterval = 15
+	defaultPredictiveHorizon  = 180
+	defaultPredictiveWindow   = 240
+	defaultAlpha              = 0.5
+	defaultBeta               = 0.3
+	defaultGamma              = 0.1
+)
+
+// startPredictive samples the request rates of the application and its
+// workloads, forecasts them a horizon ahead and pre-scales for the forecast
+// demand. The accuracy of past forecasts is logged alongside every sample.
+func (tc *Client) startPredictive(ctx context.Context) {
+	conf := tc.thresholds.Predictive
+	if conf.Interval <= 0 {
+		conf.Interval = defaultPredictiveInterval
+	}
+	if conf.Horizon <= 0 {
+		conf.Horizon = defaultPredictiveHorizon
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultPredictiveWindow
+	}
+	if conf.Alpha <= 0 {
+		conf.Alpha = defaultAlpha
+	}
+	if conf.Beta <= 0 {
+		conf.Beta = defaultBeta
+	}
+	if conf.Gamma <= 0 {
+		conf.Gamma = defaultGamma
+	}
+
+	// Number of samples to forecast ahead
+	h := int(math.Ceil(float64(conf.Horizon) / float64(conf.Interval)))
+
+	newSeries := func() *forecast.Series {
+		model := forecast.NewHoltWinters(conf.Alpha, conf.Beta, conf.Gamma, conf.SeasonLength)
+		return forecast.NewSeries(model, conf.Window)
+	}
+
+	e2eSeries := newSeries()
+	workloadSeries := make(map[string]*forecast.Series)
+
+	t := time.NewTicker(time.Duration(conf.Interval) * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-t.C:
+			kialiGraph, err := tc.getGraph(ctx, time.Minute)
+			if err != nil {
+				log.Println("error getting graph for forecast:", err)
+				continue
+			}
+			tc.observeTopology(kialiGraph, time.Minute)
+
+			currentRate, err := tc.getRequestRate(kialiGraph)
+			if err != nil {
+				log.Println("error getting request rate for forecast:", err)
+				continue
+			}
+
+			e2eSeries.Add(currentRate)
+			logAccuracy("e2e", currentRate, e2eSeries.Accuracy())
+
+			requestRates := kialiGraph.GetRequestRates()
+			for service := range workloadSeries {
+				// Keep series of workloads gone idle evenly spaced
+				if _, ok := requestRates[service]; !ok {
+					requestRates[service] = 0
+				}
+			}
+
+			for service, rate := range requestRates {
+				if service == "unknown" {
+					continue
+				}
+				if _, ok := workloadSeries[service]; !ok {
+					workloadSeries[service] = newSeries()
+				}
+				workloadSeries[service].Add(rate)
+				logAccuracy(service, rate, workloadSeries[service].Accuracy())
+			}
+
+			// Ratio of forecast demand to current demand of every service
+			ratios := make(map[string]float64)
+
+			if predicted, ok := e2eSeries.Forecast(h); ok && currentRate > 0 {
+				log.Printf("[forecast: e2e] request rate in %ds: %.1f req/s, current: %.1f req/s\n", conf.Horizon, predicted, currentRate)
+				for _, service := range tc.getEntryServices(kialiGraph) {
+					ratios[service] = predicted / currentRate
+				}
+			}
+
+			for service, series := range workloadSeries {
+				predicted, ok := series.Forecast(h)
+				last, _ := series.Last()
+				if ok && last > 0 && predicted/last > ratios[service] {
+					ratios[service] = predicted / last
+				}
+			}
+
+			if len(ratios) == 0 {
+				continue
+			}
+
+			err = tc.preScaleForRatios(ctx, kialiGraph, ratios, originPredictive, time.Now().Add(time.Duration(conf.Horizon)*time.Second))
+			if err != nil {
+				log.Println("error pre-scaling for forecast:", err)
+			}
+		}
+	}
+}
+
+func logAccuracy(name string, sample float64, accuracy forecast.Accuracy) {
+	if accuracy.Count == 0 {
+		return
+	}
+
+	log.Printf(
+		"[forecast accuracy: %s] request rate: %.1f req/s, MAE: %.2f req/s, MAPE: %.1f%% over %d forecasts\n",
+		name,
+		sample,
+		accuracy.MAE,
+		accuracy.MAPE,
+		accuracy.Count,
+	)
+}
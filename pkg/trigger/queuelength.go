@@ -0,0 +1,84 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
+
+// Sources queue lengths can be taken from
+const (
+	queueLengthSourceGraph   = "graph"
+	queueLengthSourceMetrics = "metrics"
+)
+
+// Defaults for queue lengths taken from metrics, used when they are not
+// configured
+const (
+	defaultQueueLengthWindow = 60
+	defaultQueueLengthStep   = 15
+)
+
+// getQueueLengths returns the queue length of every node in the graph. By
+// default these are read off the edges of the graph. With metrics as the
+// source, the queue length of a node is summed over the deployments backing
+// it, each being its inbound response throughput times its average request
+// duration, both averaged over the configured window, as measured by the
+// dependency source. Deployments whose metrics cannot be fetched, and every
+// node when the dependency source cannot measure workloads, keep the value
+// read off the graph, so nodes must have been resolved by getReplicaCounts
+// first.
+func (tc *Client) getQueueLengths(ctx context.Context, kialiGraph kiali.Graph) map[string]float64 {
+	queueLengths, _ := kialiGraph.GetQueueLengths()
+
+	conf := tc.thresholds.QueueLengths
+	if conf.Source != queueLengthSourceMetrics {
+		return queueLengths
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultQueueLengthWindow
+	}
+	if conf.Step <= 0 {
+		conf.Step = defaultQueueLengthStep
+	}
+
+	qs, ok := tc.queueLengthSource()
+	if !ok {
+		return queueLengths
+	}
+
+	query := kiali.MetricsQuery{
+		Duration:     time.Duration(conf.Window) * time.Second,
+		Step:         time.Duration(conf.Step) * time.Second,
+		RateInterval: time.Duration(conf.RateInterval) * time.Second,
+	}
+
+	for _, item := range kialiGraph {
+		if item.IsUnknown() {
+			continue
+		}
+
+		node := item.Name()
+		queueLength := 0.0
+		ok := true
+		for _, dep := range tc.getBackingDeployments(node) {
+			depQueueLength, err := qs.GetQueueLength(ctx, applicationNamespace, dep, query)
+			if err != nil {
+				log.Printf("error getting metrics of %s, using graph queue length: %v\n", dep, err)
+				ok = false
+				break
+			}
+
+			queueLength += depQueueLength
+		}
+
+		if ok {
+			log.Printf("[queue length: %s] graph: %f, metrics: %f\n", node, queueLengths[node], queueLength)
+			queueLengths[node] = queueLength
+		}
+	}
+
+	return queueLengths
+}
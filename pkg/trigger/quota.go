@@ -0,0 +1,36 @@
+package trigger
+
+import (
+	"context"
+	"log"
+)
+
+// fitToQuota trims a plan so that the pods it adds stay within the remaining
+// ResourceQuota of the application namespace. Pods asked for beyond the quota
+// would never be created by their ReplicaSets, so deployments are served by
+// priority instead and the ones capped are reported, along with why.
+func (tc *Client) fitToQuota(ctx context.Context, oldReplicaCounts, replicaCounts map[string]int, costs map[string]budget) (map[string]int, map[string]string, error) {
+	reasons := make(map[string]string)
+	remaining, err := tc.K8sClient.GetRemainingQuota(ctx, applicationNamespace)
+	if err != nil {
+		return replicaCounts, reasons, err
+	}
+
+	if len(remaining) == 0 {
+		return replicaCounts, reasons, nil
+	}
+
+	fitted, capped := tc.fitPlan(oldReplicaCounts, replicaCounts, costs, newBudget(remaining))
+	for dep, resourceName := range capped {
+		log.Printf(
+			"[capped by quota: %s] wanted replica count: %d, granted replica count: %d, short on: %s\n",
+			dep,
+			replicaCounts[dep],
+			fitted[dep],
+			resourceName,
+		)
+		reasons[dep] = capReason("quota", replicaCounts[dep], resourceName)
+	}
+
+	return fitted, reasons, nil
+}
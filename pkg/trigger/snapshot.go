@@ -0,0 +1,136 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	"golang.org/x/sync/errgroup"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// graphCacheTTL is how long a graph fetched from kiali is shared between
+// callers. It is kept well under the trigger interval so that every cycle
+// decides on a fresh graph.
+const graphCacheTTL = 5 * time.Second
+
+// snapshot holds everything a trigger cycle decides on, fetched once at the
+// start of the cycle so that every check and the scaling that follows read the
+// same state of the application.
+type snapshot struct {
+	// Graph of the application over the last 5 minutes
+	graph kiali.Graph
+
+	// Current replica count of every deployment, and of every node of the
+	// graph summed over the deployments backing it
+	depReplicas   map[string]int
+	replicaCounts map[string]int
+
+	// Selector of the pods of every deployment
+	depSelectors map[string]labels.Selector
+
+	// Current metrics of every deployment, with the custom and external
+	// metrics thresholds are set on
+	depMetrics map[string]Resources
+
+	// Inbound request error ratio of degraded workloads, empty unless health
+	// checks are enabled
+	degraded map[string]float64
+}
+
+// getGraphCache returns the cache graphs are fetched through, creating it on
+// first use
+func (tc *Client) getGraphCache() *kiali.GraphCache {
+	tc.graphsOnce.Do(func() {
+		tc.graphs = kiali.NewGraphCache(tc.source(), graphCacheTTL)
+	})
+
+	return tc.graphs
+}
+
+// getGraph returns the graph of the application over the given duration.
+// Graphs are shared with other callers asking for the same graph at about the
+// same time, see kiali.GraphCache.
+func (tc *Client) getGraph(ctx context.Context, duration time.Duration) (kiali.Graph, error) {
+	return tc.getGraphCache().GetGraph(ctx, tc.graphQuery(duration))
+}
+
+// CachedGraph gives the graph described by a query, shared with the trigger
+// and other callers asking for the same graph at about the same time. Graphs
+// are shared and must not be modified.
+func (tc *Client) CachedGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error) {
+	return tc.getGraphCache().GetGraph(ctx, query)
+}
+
+// takeSnapshot fetches the graph, deployments and, if enabled, the health of
+// the application concurrently, and resolves the nodes of the graph to their
+// replica counts. Pod metrics, along with the custom and external metrics
+// thresholds are set on, are read once the deployments are known.
+func (tc *Client) takeSnapshot(ctx context.Context) (*snapshot, error) {
+	s := &snapshot{
+		depReplicas: make(map[string]int),
+		degraded:    make(map[string]float64),
+	}
+
+	var deployments []appsv1.Deployment
+	eg, egCtx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		graph, err := tc.getGraph(egCtx, 5*time.Minute)
+		if err != nil {
+			return err
+		}
+		tc.observeTopology(graph, 5*time.Minute)
+
+		s.graph = graph
+		return nil
+	})
+
+	eg.Go(func() error {
+		var err error
+		deployments, err = tc.K8sClient.GetDeployments(egCtx, applicationNamespace)
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range deployments {
+			s.depReplicas[dep.Name] = int(dep.Status.Replicas)
+		}
+		s.depSelectors = deploymentSelectors(deployments)
+		return nil
+	})
+
+	if tc.thresholds.Health.Enabled {
+		eg.Go(func() error {
+			// Scaling goes ahead on the other signals without health
+			degraded, err := tc.getDegradedWorkloads(egCtx)
+			if err != nil {
+				log.Println("error getting workload health:", err)
+				return nil
+			}
+			s.degraded = degraded
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	s.depMetrics = tc.getDeploymentMetrics(ctx, s.depSelectors)
+	for dep, values := range tc.getCustomMetrics(ctx, s.depSelectors) {
+		metrics := s.depMetrics[dep]
+		metrics.Custom = values
+		s.depMetrics[dep] = metrics
+	}
+
+	replicaCounts, err := tc.resolveReplicaCounts(ctx, s.graph, deployments)
+	if err != nil {
+		return nil, err
+	}
+	s.replicaCounts = replicaCounts
+
+	return s, nil
+}
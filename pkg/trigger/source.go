@@ -0,0 +1,456 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/linkerd"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/prometheus"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/traces"
+	graph "github.com/kiali/kiali/graph/config/cytoscape"
+)
+
+// Sources the dependency graph can be taken from
+const (
+	SourceKiali      = "kiali"
+	SourcePrometheus = "prometheus"
+	SourceStatic     = "static"
+	SourceTraces     = "traces"
+	SourceLinkerd    = "linkerd"
+)
+
+// Trace backends the dependency graph can be read from
+const (
+	TraceBackendJaeger = "jaeger"
+	TraceBackendZipkin = "zipkin"
+	TraceBackendOTLP   = "otlp"
+)
+
+var errNoPrometheus = errors.New("no prometheus host configured")
+
+// DependencySource yields the dependency graph of the application, with the
+// request rate, latency and throughput of every edge
+type DependencySource interface {
+	GetGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error)
+}
+
+// HealthSource yields the health kiali computes for the workloads of a
+// namespace, see kiali.Client.GetWorkloadHealth. Dependency sources without a
+// health API do not implement it, and health checks are skipped with them.
+type HealthSource interface {
+	GetWorkloadHealth(ctx context.Context, namespace string, rateInterval time.Duration) (map[string]*kiali.WorkloadHealth, error)
+}
+
+// QueueLengthSource yields the queue length of a workload over the window of
+// a metrics query, its inbound response throughput times its average request
+// duration. Dependency sources which cannot measure single workloads do not
+// implement it, and queue lengths are read off the graph with them.
+type QueueLengthSource interface {
+	GetQueueLength(ctx context.Context, namespace, workload string, query kiali.MetricsQuery) (float64, error)
+}
+
+// SourceConfig picks where the dependency graph is taken from. Type "kiali",
+// the default, fetches it from kiali. Type "prometheus" builds the workload
+// graph straight from the Istio telemetry in prometheus. Type "static" takes
+// the graph declared in Static, for clusters without a service mesh. Type
+// "traces" builds the graph from distributed traces as set up in Traces. Type
+// "linkerd" builds the deployment graph from the metrics of the Linkerd
+// proxies in the prometheus Linkerd scrapes.
+type SourceConfig struct {
+	Type   string      `json:"type"`
+	Static StaticGraph `json:"static"`
+	Traces TraceConfig `json:"traces"`
+}
+
+// TraceConfig sets up reading traces. Backend "jaeger" and "zipkin" query the
+// API at Scheme, Host and Port for up to Limit traces (100 when 0) of each of
+// Services, or of every service the backend knows if empty. A service whose
+// read hits the limit narrows the window rates are taken over to the time
+// since its oldest trace. Backend "otlp" reads the OTLP JSON files at Files.
+// SampleRate is the fraction of requests traced, 1 when 0, which call rates
+// are scaled up by. ResponseSize is the size in bytes taken for the responses
+// of calls whose spans record none, which gives them their throughput.
+type TraceConfig struct {
+	Backend      string   `json:"backend"`
+	Scheme       string   `json:"scheme"`
+	Host         string   `json:"host"`
+	Port         int      `json:"port"`
+	Services     []string `json:"services"`
+	Limit        int      `json:"limit"`
+	Files        []string `json:"files"`
+	SampleRate   float64  `json:"sampleRate"`
+	ResponseSize float64  `json:"responseSize"`
+}
+
+// StaticGraph declares the dependencies of an application whose traffic no
+// mesh reports. Requests into every workload called from outside the
+// application are counted by RateQuery, a prometheus query in which $namespace,
+// $workload and $window stand for the namespace and name of the workload and
+// the window rates are taken over. The rates of the other edges follow from
+// their ratios.
+type StaticGraph struct {
+	RateQuery string       `json:"rateQuery"`
+	Edges     []StaticEdge `json:"edges"`
+}
+
+// StaticEdge declares that every request into Source leads to Ratio requests
+// from it to Target, each taking Latency milliseconds and answered with
+// ResponseSize bytes on average. A Source of "unknown" stands for callers
+// outside the application.
+type StaticEdge struct {
+	Source       string  `json:"source"`
+	Target       string  `json:"target"`
+	Ratio        float64 `json:"ratio"`
+	Latency      float64 `json:"latency"`
+	ResponseSize float64 `json:"responseSize"`
+}
+
+// NewDependencySource returns the source described by conf. Kiali graphs are
+// fetched with kc, and prometheus is queried with pc, which may be nil if
+// neither the prometheus nor the static source is used. Trace backends are
+// set up from conf alone.
+func NewDependencySource(conf SourceConfig, kc *kiali.Client, pc *prometheus.Client) (DependencySource, error) {
+	switch conf.Type {
+	case "", SourceKiali:
+		return kc, nil
+
+	case SourcePrometheus:
+		if pc == nil {
+			return nil, errNoPrometheus
+		}
+		return &prometheusSource{client: pc}, nil
+
+	case SourceStatic:
+		if pc == nil {
+			return nil, errNoPrometheus
+		}
+		if err := conf.Static.Validate(); err != nil {
+			return nil, err
+		}
+		return &staticSource{graph: conf.Static, client: pc}, nil
+
+	case SourceTraces:
+		return newTraceSource(conf.Traces)
+
+	case SourceLinkerd:
+		if pc == nil {
+			return nil, errNoPrometheus
+		}
+		return &linkerdSource{client: linkerd.NewLinkerdClient(pc)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown dependency source %q", conf.Type)
+}
+
+// source returns the source the dependency graph is taken from, kiali if
+// none was set
+func (tc *Client) source() DependencySource {
+	if tc.Source != nil {
+		return tc.Source
+	}
+
+	return tc.KialiClient
+}
+
+// healthSource returns the source workload health is taken from, which is the
+// dependency source if it has a health API. Otherwise it logs, once, that
+// health checks are skipped.
+func (tc *Client) healthSource() (HealthSource, bool) {
+	hs, ok := tc.source().(HealthSource)
+	if !ok {
+		tc.noHealthOnce.Do(func() {
+			log.Printf("[health] dependency source %q has no health API, skipping health checks\n", tc.thresholds.Source.Type)
+		})
+	}
+
+	return hs, ok
+}
+
+// queueLengthSource returns the source queue lengths of single workloads are
+// taken from, which is the dependency source if it can measure them.
+// Otherwise it logs, once, that queue lengths are read off the graph.
+func (tc *Client) queueLengthSource() (QueueLengthSource, bool) {
+	qs, ok := tc.source().(QueueLengthSource)
+	if !ok {
+		tc.noQueueLengthOnce.Do(func() {
+			log.Printf("[queue length] dependency source %q cannot measure workloads, using graph queue lengths\n", tc.thresholds.Source.Type)
+		})
+	}
+
+	return qs, ok
+}
+
+// prometheusSource builds workload graphs from the Istio telemetry in
+// prometheus
+type prometheusSource struct {
+	client *prometheus.Client
+}
+
+func (s *prometheusSource) GetGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error) {
+	if query.GraphType != "" && query.GraphType != kiali.GraphTypeWorkload {
+		return nil, fmt.Errorf("prometheus only builds workload graphs, got %q", query.GraphType)
+	}
+
+	return s.client.GetGraph(ctx, prometheus.GraphQuery{
+		Namespaces: query.Namespaces,
+		Duration:   query.Duration,
+		QueryTime:  query.QueryTime,
+	})
+}
+
+// GetQueueLength takes the queue length of a workload from the Istio telemetry
+// in prometheus, over the duration of the query
+func (s *prometheusSource) GetQueueLength(ctx context.Context, namespace, workload string, query kiali.MetricsQuery) (float64, error) {
+	return s.client.GetQueueLength(ctx, namespace, workload, query.Duration, query.QueryTime)
+}
+
+// linkerdSource builds deployment graphs from the metrics of the Linkerd
+// proxies
+type linkerdSource struct {
+	client *linkerd.Client
+}
+
+func (s *linkerdSource) GetGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error) {
+	if query.GraphType != "" && query.GraphType != kiali.GraphTypeWorkload {
+		return nil, fmt.Errorf("linkerd only builds workload graphs, got %q", query.GraphType)
+	}
+
+	return s.client.GetGraph(ctx, prometheus.GraphQuery{
+		Namespaces: query.Namespaces,
+		Duration:   query.Duration,
+		QueryTime:  query.QueryTime,
+	})
+}
+
+// Validate checks that a static graph declares edges that can be followed.
+func (g StaticGraph) Validate() error {
+	if g.RateQuery == "" {
+		return errors.New("static graph has no rate query")
+	}
+
+	for _, edge := range g.Edges {
+		if edge.Source == "" || edge.Target == "" {
+			return fmt.Errorf("static edge from %q to %q is missing an end", edge.Source, edge.Target)
+		}
+		if edge.Target == kiali.NodeTypeUnknown {
+			return fmt.Errorf("static edge from %q leads out of the application", edge.Source)
+		}
+		if edge.Ratio < 0 || edge.Latency < 0 || edge.ResponseSize < 0 {
+			return fmt.Errorf("static edge from %q to %q has a negative ratio, latency or response size", edge.Source, edge.Target)
+		}
+	}
+
+	return nil
+}
+
+// staticSource serves a declared graph with rates from prometheus
+type staticSource struct {
+	graph  StaticGraph
+	client *prometheus.Client
+}
+
+// GetGraph returns the declared graph as a workload graph of the first
+// namespace of the query. The rate into every workload called from outside
+// is queried, and the rates of the other edges are worked out downstream from
+// their ratios. Edges in call cycles get the rate reaching their source from
+// outside the cycle. Edges carry their request rate times their declared
+// response size as their response throughput in bytes per second, as the
+// graphs of kiali do.
+func (s *staticSource) GetGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error) {
+	if len(query.Namespaces) == 0 {
+		return nil, errors.New("no namespace to build a graph of")
+	}
+	namespace := query.Namespaces[0]
+
+	// Requests coming into every workload
+	inbound := make(map[string]float64)
+	rates := make([]float64, len(s.graph.Edges))
+	for i, edge := range s.graph.Edges {
+		if edge.Source != kiali.NodeTypeUnknown {
+			continue
+		}
+
+		rate, err := s.getRate(ctx, namespace, edge.Target, query)
+		if err != nil {
+			return nil, err
+		}
+		rates[i] = rate
+		inbound[edge.Target] += rate
+	}
+
+	// Follow the ratios downstream, in declaration order until nothing
+	// changes, each edge counted once
+	counted := make([]bool, len(s.graph.Edges))
+	for changed := true; changed; {
+		changed = false
+		for i, edge := range s.graph.Edges {
+			if counted[i] || edge.Source == kiali.NodeTypeUnknown || !s.isSettled(edge.Source, counted) {
+				continue
+			}
+
+			rates[i] = inbound[edge.Source] * edge.Ratio
+			inbound[edge.Target] += rates[i]
+			counted[i] = true
+			changed = true
+		}
+	}
+
+	// Edges in cycles never settle, and take the rate known so far
+	for i, edge := range s.graph.Edges {
+		if !counted[i] && edge.Source != kiali.NodeTypeUnknown {
+			rates[i] = inbound[edge.Source] * edge.Ratio
+		}
+	}
+
+	g, _ := kiali.MakeGraph(s.config(namespace, query, rates))
+	return g, nil
+}
+
+// isSettled reports whether every edge into a workload but those from
+// callers outside has been counted
+func (s *staticSource) isSettled(workload string, counted []bool) bool {
+	for i, edge := range s.graph.Edges {
+		if edge.Target == workload && edge.Source != kiali.NodeTypeUnknown && !counted[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getRate queries the request rate into a workload
+func (s *staticSource) getRate(ctx context.Context, namespace, workload string, query kiali.GraphQuery) (float64, error) {
+	promQL := strings.NewReplacer(
+		"$namespace", namespace,
+		"$workload", workload,
+		"$window", fmt.Sprintf("%ds", int64(query.Duration/time.Second)),
+	).Replace(s.graph.RateQuery)
+
+	vector, err := s.client.Query(ctx, promQL, query.QueryTime)
+	if err != nil {
+		return 0, err
+	}
+
+	rate := 0.0
+	for _, sample := range vector {
+		rate += sample.Value.Value
+	}
+
+	return rate, nil
+}
+
+// config returns the declared graph with the given edge rates as a kiali
+// graph config
+func (s *staticSource) config(namespace string, query kiali.GraphQuery, rates []float64) *graph.Config {
+	conf := &graph.Config{
+		Timestamp: time.Now().Unix(),
+		Duration:  int64(query.Duration / time.Second),
+		GraphType: kiali.GraphTypeWorkload,
+	}
+
+	hasInbound := make(map[string]bool)
+	for _, edge := range s.graph.Edges {
+		hasInbound[edge.Target] = true
+	}
+
+	added := make(map[string]bool)
+	addNode := func(workload string) {
+		if added[workload] {
+			return
+		}
+		added[workload] = true
+
+		node := &graph.NodeData{
+			ID:        workload,
+			NodeType:  kiali.NodeTypeWorkload,
+			Namespace: namespace,
+			Workload:  workload,
+			IsRoot:    !hasInbound[workload],
+		}
+		if workload == kiali.NodeTypeUnknown {
+			node.NodeType = kiali.NodeTypeUnknown
+			node.Namespace = kiali.NodeTypeUnknown
+		}
+		conf.Elements.Nodes = append(conf.Elements.Nodes, &graph.NodeWrapper{Data: node})
+	}
+
+	for i, edge := range s.graph.Edges {
+		addNode(edge.Source)
+		addNode(edge.Target)
+
+		rate := strconv.FormatFloat(rates[i], 'f', 2, 64)
+		conf.Elements.Edges = append(conf.Elements.Edges, &graph.EdgeWrapper{Data: &graph.EdgeData{
+			ID:           fmt.Sprintf("e%d", i),
+			Source:       edge.Source,
+			Target:       edge.Target,
+			ResponseTime: strconv.FormatFloat(edge.Latency, 'f', 2, 64),
+			Throughput:   strconv.FormatFloat(rates[i]*edge.ResponseSize, 'f', 0, 64),
+			Traffic: graph.ProtocolTraffic{
+				Protocol: "http",
+				Rates:    map[string]string{"http": rate},
+			},
+		}})
+	}
+
+	return conf
+}
+
+// traceSource builds call graphs from distributed traces
+type traceSource struct {
+	reader       traces.Reader
+	sampleRate   float64
+	responseSize float64
+	files        bool
+}
+
+func newTraceSource(conf TraceConfig) (*traceSource, error) {
+	if conf.ResponseSize < 0 {
+		return nil, errors.New("negative trace response size")
+	}
+
+	s := &traceSource{sampleRate: conf.SampleRate, responseSize: conf.ResponseSize}
+
+	switch conf.Backend {
+	case TraceBackendJaeger:
+		s.reader = traces.NewJaegerClient(conf.Scheme, conf.Host, conf.Port, nil, conf.Services, conf.Limit)
+	case TraceBackendZipkin:
+		s.reader = traces.NewZipkinClient(conf.Scheme, conf.Host, conf.Port, nil, conf.Services, conf.Limit)
+	case TraceBackendOTLP:
+		if len(conf.Files) == 0 {
+			return nil, errors.New("no otlp trace files configured")
+		}
+		s.reader = traces.NewOTLPFiles(conf.Files)
+		s.files = true
+	default:
+		return nil, fmt.Errorf("unknown trace backend %q", conf.Backend)
+	}
+
+	return s, nil
+}
+
+// GetGraph returns the call graph of the traces seen over the duration of the
+// query as a workload graph of its first namespace, see traces.CallGraph.
+// Rates are taken over the time the traces cover in full: trace files are
+// read whole, and reads hitting their limit only cover their latest traces.
+func (s *traceSource) GetGraph(ctx context.Context, query kiali.GraphQuery) (kiali.Graph, error) {
+	if len(query.Namespaces) == 0 {
+		return nil, errors.New("no namespace to build a graph of")
+	}
+
+	ts, covered, err := s.reader.GetTraces(ctx, query.Duration, query.QueryTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.files && covered < query.Duration {
+		log.Printf("[traces] read limit reached, taking rates over the last %v of %v\n", covered.Round(time.Second), query.Duration)
+	}
+
+	return traces.NewCallGraph(ts, covered).Graph(query.Namespaces[0], s.sampleRate, s.responseSize), nil
+}
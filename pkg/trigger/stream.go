@@ -0,0 +1,44 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/metricscraper"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SubscribeDeploymentMetrics returns a stream of the resource usage of every
+// deployment of the application, sampled every freq Duration, see
+// metricscraper.Client.StreamDeploymentMetrics. Deployments are matched to
+// their pods by the selectors they have when subscribing. The trigger itself
+// does not subscribe, as every scaling cycle takes its own snapshot of the
+// application, it is meant for watching usage from outside, e.g. dashboards.
+func (tc *Client) SubscribeDeploymentMetrics(ctx context.Context, freq time.Duration) (<-chan metricscraper.DeploymentMetricsResult, error) {
+	deployments, err := tc.K8sClient.GetDeployments(ctx, applicationNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return tc.MetricClient.StreamDeploymentMetrics(ctx, applicationNamespace, deploymentSelectors(deployments), freq), nil
+}
+
+// deploymentSelectors returns the selector of the pods of every deployment,
+// keyed by deployment name. Deployments whose selector cannot be read are
+// logged and left out.
+func deploymentSelectors(deployments []appsv1.Deployment) map[string]labels.Selector {
+	selectors := make(map[string]labels.Selector)
+	for _, dep := range deployments {
+		selector, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+		if err != nil {
+			log.Printf("error reading selector of deployment %s: %s\n", dep.Name, err)
+			continue
+		}
+		selectors[dep.Name] = selector
+	}
+
+	return selectors
+}
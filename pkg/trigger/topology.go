@@ -0,0 +1,50 @@
+package trigger
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
+
+// observeTopology compares a freshly fetched graph with the last one seen
+// over the same duration, as graphs over different durations see different
+// edges. When nodes or edges were added or removed, the change is logged and what the
+// trigger learnt about the old topology is dropped: the deployments resolved
+// for every node and the state of the PID controller.
+func (tc *Client) observeTopology(kialiGraph kiali.Graph, duration time.Duration) {
+	tc.topologyMu.Lock()
+	defer tc.topologyMu.Unlock()
+
+	if tc.lastGraphs == nil {
+		tc.lastGraphs = make(map[time.Duration]kiali.Graph)
+	}
+
+	last := tc.lastGraphs[duration]
+	tc.lastGraphs[duration] = kialiGraph
+	if last == nil {
+		return
+	}
+
+	d := kiali.Diff(last, kialiGraph, kiali.DefaultDiffThresholds)
+	if !d.TopologyChanged() {
+		return
+	}
+
+	log.Printf("[topology changed]\n%s\n", strings.TrimSpace(d.String()))
+
+	tc.nodesMu.Lock()
+	tc.nodes = nil
+	tc.nodesMu.Unlock()
+
+	tc.topologyEpoch++
+}
+
+// getTopologyEpoch returns how many times the topology changed so far
+func (tc *Client) getTopologyEpoch() int {
+	tc.topologyMu.Lock()
+	defer tc.topologyMu.Unlock()
+
+	return tc.topologyEpoch
+}
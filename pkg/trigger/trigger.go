@@ -1,21 +1,23 @@
 package trigger
 
 import (
-	"container/list"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"math"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+	"github.com/Gituser143/stunning-octo-enigma/pkg/metricscraper"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 // StartTrigger runs the trigger indefinetely and checks for violations every 30 seconds
@@ -23,6 +25,14 @@ func (tc *Client) StartTrigger(ctx context.Context) error {
 	t := time.NewTicker(15 * time.Second)
 	thresholds := tc.thresholds
 
+	if thresholds.FeedForward.Enabled {
+		go tc.startFeedForward(ctx)
+	}
+
+	if thresholds.Predictive.Enabled {
+		go tc.startPredictive(ctx)
+	}
+
 	// go func() {
 	// 	logTicker := time.NewTicker(3 * time.Second)
 	// 	f, err := os.OpenFile("throughput.csv", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -51,25 +61,26 @@ func (tc *Client) StartTrigger(ctx context.Context) error {
 	// }()
 
 	for {
-		eg, egCtx := errgroup.WithContext(ctx)
-
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 
 		case <-t.C:
-			// Check for throughput violations
-			eg.Go(func() error {
-				return tc.checkThroughput(egCtx, thresholds.Throughput)
-			})
+			if thresholds.PID.Enabled {
+				// Close the loop on throughput, resources are still checked below
+				if err := tc.controlThroughput(ctx); err != nil {
+					log.Println("error controlling throughput:", err)
+				}
+			}
 
-			// Check for resource thresholds exceeding and get corresponding
-			// deployments
-			eg.Go(func() error {
-				return tc.checkResources(egCtx)
-			})
+			// Fetch what this cycle decides on once, so that the checks and
+			// the scaling that follows all see the same application
+			s, err := tc.takeSnapshot(ctx)
+			if err == nil {
+				err = tc.checkSnapshot(s)
+			}
 
-			if err := eg.Wait(); err != nil {
+			if err != nil {
 				if errors.Is(err, errScaleApplication) {
 					log.Println("trigger client triggered")
 					log.Println("fetching base deployments to scale")
@@ -80,16 +91,19 @@ func (tc *Client) StartTrigger(ctx context.Context) error {
 					// 	Scale function should then calculate effect of scaling to
 					// 	donwstream services
 
-					depCtx, cancel := context.WithCancel(ctx)
-					defer cancel()
-
-					baseDeps, err := tc.getBaseDeployments(depCtx)
-					if err != nil && errors.Is(err, errScaleApplication) {
+					baseDeps, err := tc.getBaseDeployments(s)
+					if (err != nil && errors.Is(err, errScaleApplication)) || len(s.degraded) > 0 {
 						log.Println("Deployments to scale are:", baseDeps)
-						tc.scaleDeployements(depCtx, baseDeps)
+						if err := tc.scaleDeployements(ctx, s, baseDeps); err != nil {
+							log.Println("error scaling deployments:", err)
+						}
 					}
 				} else if errors.Is(err, context.Canceled) {
 					// log.Println(err)
+				} else if errors.Is(err, kiali.ErrUnauthorized) {
+					log.Println("kiali rejected the configured credentials, not scaling:", err)
+				} else if errors.Is(err, kiali.ErrServerError) || errors.Is(err, kiali.ErrDecode) {
+					log.Println("kiali could not serve a usable graph, not scaling:", err)
 				} else {
 					log.Println("no resource thresholds crossed, not scaling")
 					// return err
@@ -99,6 +113,58 @@ func (tc *Client) StartTrigger(ctx context.Context) error {
 	}
 }
 
+// checkSnapshot checks a snapshot for throughput, resource and health
+// violations, returning errScaleApplication if any is found. Throughput is
+// left to the PID controller when it is enabled, and errors checking it are
+// logged so that the other checks still run.
+func (tc *Client) checkSnapshot(s *snapshot) error {
+	thresholds := tc.thresholds
+	violated := false
+
+	if !thresholds.PID.Enabled {
+		// Check for throughput violations. A throughput that cannot be
+		// measured, e.g. on a graph without entry points, leaves the other
+		// checks to decide.
+		err := tc.checkThroughput(s, thresholds.Throughput)
+		if errors.Is(err, errScaleApplication) {
+			violated = true
+		} else if err != nil {
+			log.Println("error checking throughput:", err)
+		}
+	}
+
+	// Check for resource thresholds exceeding
+	if err := tc.checkResources(s); err != nil {
+		violated = true
+	}
+
+	if thresholds.Health.Enabled {
+		// Check for workloads failing requests
+		if err := tc.checkHealth(s); err != nil {
+			violated = true
+		}
+	}
+
+	if violated {
+		return errScaleApplication
+	}
+
+	return nil
+}
+
+// graphQuery returns the query for the graph of the application over the
+// given duration, with average response times and response throughput.
+func (tc *Client) graphQuery(duration time.Duration) kiali.GraphQuery {
+	return kiali.GraphQuery{
+		GraphType:    tc.thresholds.GraphType,
+		Namespaces:   []string{applicationNamespace},
+		Duration:     duration,
+		ResponseTime: kiali.ResponseTimeAvg,
+		Throughput:   kiali.ThroughputResponse,
+		DeadNode:     true,
+	}
+}
+
 func (tc *Client) getQueueLengthThresholds(fileName string) map[string]float64 {
 
 	queueLengthThresholds := make(map[string]float64)
@@ -117,95 +183,227 @@ func (tc *Client) getQueueLengthThresholds(fileName string) map[string]float64 {
 	return queueLengthThresholds
 }
 
-func (tc *Client) scaleDeployements(ctx context.Context, baseDeps map[string]Resources) error {
+func (tc *Client) scaleDeployements(ctx context.Context, s *snapshot, baseDeps map[string]Resources) error {
+
+	kialiGraph := s.graph
+
+	// Initializes the replica count to the current replica count for each service
+	oldReplicaCounts := s.replicaCounts
 
-	// Initialize an empty list used as a queue for BFS
-	graphQueue := list.New()
+	queueLengths := tc.getQueueLengths(ctx, kialiGraph)
+
+	if path, responseTime := kialiGraph.CriticalPath(kialiGraph.SelectEntries(tc.thresholds.Entry)); len(path) > 0 {
+		log.Printf("[critical path] %s, %.1fms\n", strings.Join(nodeNames(kialiGraph, path), " -> "), responseTime)
+	}
 
-	// Maintains the replica counts of each service
-	oldReplicaCounts := make(map[string]int)
 	replicaCounts := make(map[string]int)
+	for service, replicaCount := range oldReplicaCounts {
+		replicaCounts[service] = replicaCount
+	}
+
+	optimise := tc.thresholds.Optimiser.Enabled
+	podBudget := 0
+	if optimise {
+		var err error
+		podBudget, err = tc.getPodBudget(ctx)
+		if errors.Is(err, errNoPodBudget) {
+			log.Println("optimiser has no pod budget, scaling by formula:", err)
+			optimise = false
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if optimise {
+		// Spread a fixed budget of pods across the graph
+		replicaCounts = tc.optimiseReplicaCounts(kialiGraph, queueLengths, baseDeps, oldReplicaCounts, podBudget)
+
+		// Degraded workloads get at least what their error ratio asks for,
+		// on top of the budget
+		tc.scaleDegradedWorkloads(s.degraded, oldReplicaCounts, replicaCounts)
+	} else {
+		baseDependenciesNewReplicaCount := tc.getNewReplicaCounts(baseDeps, s.depReplicas)
+
+		// Iterates through base dependencies
+		// Calculates new replica count for them ( based on HPA )
+		// and scales their child nodes by BFS
+		services := []string{}
+		for dep, replicaCount := range baseDependenciesNewReplicaCount {
+			service := tc.getNodeForDeployment(dep)
+			if service != dep {
+				// Grow the node backed by the deployment by the same ratio
+				currentReplicaCount := s.depReplicas[dep]
+				if currentReplicaCount <= 0 {
+					continue
+				}
+				replicaCount = int64(math.Ceil(float64(oldReplicaCounts[service]) * float64(replicaCount) / float64(currentReplicaCount)))
+			}
 
-	namespaces := []string{applicationNamespace}
-	parameters := map[string]string{
-		"responseTime": "avg",
-		"throughput":   "response",
-		"duration":     "5m",
+			log.Printf(
+				"[hpa rc for %s] old replica count: %d, new replica count: %d\n",
+				service,
+				replicaCounts[service],
+				replicaCount,
+			)
+			replicaCounts[service] = (int)(math.Max(float64(replicaCounts[service]), float64(replicaCount)))
+			services = append(services, service)
+		}
+
+		// Workloads failing requests are scaled up too
+		services = append(services, tc.scaleDegradedWorkloads(s.degraded, oldReplicaCounts, replicaCounts)...)
+
+		tc.propagateReplicaCounts(kialiGraph, queueLengths, oldReplicaCounts, replicaCounts, services)
 	}
 
-	kialiGraph, err := tc.KialiClient.GetWorkloadGraph(ctx, namespaces, parameters)
+	// Hold back unhealthy nodes and trim the plan down to what the quota allows
+	// and the cluster can schedule
+	replicaCounts, caps := tc.boundPlan(ctx, oldReplicaCounts, replicaCounts)
+
+	tc.applyReplicaCounts(ctx, kialiGraph, oldReplicaCounts, replicaCounts, caps, originReactive, time.Time{})
+
+	return nil
+}
+
+// getReplicaCounts returns the current replica count of every node in the
+// graph, summed over the deployments backing it. Nodes no deployment backs
+// are left out.
+func (tc *Client) getReplicaCounts(ctx context.Context, kialiGraph kiali.Graph) (map[string]int, error) {
+	deployments, err := tc.K8sClient.GetDeployments(ctx, applicationNamespace)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	queueLengths, _ := kialiGraph.GetQueueLengths()
+	return tc.resolveReplicaCounts(ctx, kialiGraph, deployments)
+}
 
-	// Initializes the replica count to the current replica count for each service
+// resolveReplicaCounts returns the replica count of every node in the graph,
+// summed over the current replica counts of the given deployments backing it.
+// Nodes are resolved to deployments against the deployments given, and the
+// services of the application are listed once for service graphs, rather than
+// calling the API for every node. Nodes no deployment backs are left out.
+func (tc *Client) resolveReplicaCounts(ctx context.Context, kialiGraph kiali.Graph, deployments []appsv1.Deployment) (map[string]int, error) {
+	serviceSelectors, err := tc.getServiceSelectors(ctx, kialiGraph)
+	if err != nil {
+		return nil, err
+	}
+
+	depReplicas := make(map[string]int)
+	for _, dep := range deployments {
+		depReplicas[dep.Name] = int(dep.Status.Replicas)
+	}
+
+	replicaCounts := make(map[string]int)
 	for _, item := range kialiGraph {
-		if item.Node.Workload == "unknown" {
+		if item.IsUnknown() {
 			continue
-		} else {
-			currentReplicaCount, err := tc.K8sClient.GetCurrentReplicaCount(ctx, applicationNamespace, item.Node.Workload)
+		}
+
+		deps := resolveDeployments(item, deployments, serviceSelectors)
+		if len(deps) == 0 {
+			continue
+		}
+
+		for _, dep := range deps {
+			replicaCounts[item.Name()] += depReplicas[dep]
+		}
+		tc.setBackingDeployments(item.Name(), deps)
+	}
+
+	return replicaCounts, nil
+}
 
+// applyReplicaCounts scales up every node whose replica count in the plan is
+// higher than its current one and records a ScalingEvent for it. Nodes the
+// plan was capped for are recorded along with why, see boundPlan, and a plan
+// that scaled any node is kept along with the graph it was decided on, see
+// LastPlan. For feed-forward scaling, stepStart holds the start of the load
+// step scaled for.
+func (tc *Client) applyReplicaCounts(ctx context.Context, kialiGraph kiali.Graph, oldReplicaCounts, replicaCounts map[string]int, caps map[string]string, origin string, stepStart time.Time) {
+	tc.recordDecisions(oldReplicaCounts, replicaCounts, caps, origin)
+	scaled := false
+
+	for service, replicaCount := range replicaCounts {
+		if replicaCount > oldReplicaCounts[service] {
+			log.Printf(
+				"[replicas for: %s] old replica count: %d, new replica count: %d\n",
+				service,
+				oldReplicaCounts[service],
+				replicaCount,
+			)
+
+			err := tc.scaleNode(ctx, service, oldReplicaCounts[service], replicaCount)
 			if err != nil {
-				return err
+				log.Printf("error scaling %s: %v\n", service, err)
+				continue
 			}
 
-			replicaCounts[item.Node.Workload] = (int)(currentReplicaCount)
-			oldReplicaCounts[item.Node.Workload] = (int)(currentReplicaCount)
+			tc.recordScalingEvent(ScalingEvent{
+				Time:        time.Now(),
+				Origin:      origin,
+				Service:     service,
+				OldReplicas: oldReplicaCounts[service],
+				NewReplicas: replicaCount,
+				StepStart:   stepStart,
+			})
+			scaled = true
 		}
 	}
 
-	baseDependenciesNewReplicaCount, err := tc.getNewReplicaCounts(ctx, baseDeps, applicationNamespace)
-	if err != nil {
-		return err
+	if scaled {
+		tc.recordPlan(kialiGraph, oldReplicaCounts, origin)
 	}
+}
 
-	// Iterates through base dependencies
-	// Calculates new replica count for them ( based on HPA )
-	// Pushes the base dependencies into the graphQueue
-	// to perform BFS on it's child nodes
-	for service, replicaCount := range baseDependenciesNewReplicaCount {
-		log.Printf(
-			"[hpa rc for %s] old replica count: %d, new replica count: %d\n",
-			service,
-			replicaCounts[service],
-			replicaCount,
-		)
-		replicaCounts[service] = (int)(math.Max(float64(replicaCounts[service]), float64(replicaCount)))
-		graphQueue.PushBack(service)
+// propagateReplicaCounts walks the graph in topological order from the given
+// services, whose replica counts have already been raised in replicaCounts, and
+// raises the replica counts of downstream services whose estimated queue
+// lengths would cross their thresholds. Every raised service passes its effect
+// on once, so call cycles are walked through once rather than around. A
+// child's queue length grows with its parent's replica count, or, where the
+// graph knows the fan-out of the edge between them, by the extra calls the
+// parent's extra requests lead to.
+func (tc *Client) propagateReplicaCounts(
+	kialiGraph kiali.Graph,
+	queueLengths map[string]float64,
+	oldReplicaCounts map[string]int,
+	replicaCounts map[string]int,
+	services []string,
+) {
+	raised := make(map[string]bool)
+	for _, service := range services {
+		raised[service] = true
 	}
 
 	queueLengthThresholds := tc.getQueueLengthThresholds("queue.json")
 
-	idMap := make(map[string]string)
-	for id, item := range kialiGraph {
-		idMap[item.Node.Workload] = id
+	for _, cycle := range kialiGraph.Cycles() {
+		log.Printf("[call cycle] %s\n", strings.Join(nodeNames(kialiGraph, cycle), " -> "))
 	}
 
-	for {
-		if graphQueue.Len() == 0 {
-			break
-		}
-
-		currentNode := graphQueue.Front()
-		// curentService here refers to the parent service
-		currentService := idMap[fmt.Sprintf("%v", currentNode.Value)]
-		currentServiceName := fmt.Sprintf("%v", currentNode.Value)
-		log.Printf("calculating effect for service %s\n", currentNode.Value)
-		graphQueue.Remove(currentNode)
-
-		if _, ok := kialiGraph[currentService]; !ok {
+	for _, id := range kialiGraph.TopologicalOrder() {
+		// currentService here refers to the parent service
+		currentService := kialiGraph[id].Name()
+		if !raised[currentService] {
 			continue
 		}
+		log.Printf("calculating effect for service %s\n", currentService)
 
-		for _, edge := range kialiGraph[currentService].Edges {
-			if edge == nil {
-				continue
-			}
+		for _, target := range kialiGraph.Successors(id) {
 			// serviceToScale refers to the child service
-			serviceToScale := kialiGraph[edge.Target].Node.Workload
-			newQueueLength := queueLengths[serviceToScale] * float64(replicaCounts[currentServiceName]) / float64(oldReplicaCounts[currentServiceName])
+			serviceToScale := kialiGraph[target].Name()
+			newQueueLength := queueLengths[serviceToScale] * float64(replicaCounts[currentService]) / float64(oldReplicaCounts[currentService])
+
+			// Where the source knows how many calls every request leads to,
+			// only the share of the child's requests coming from the parent
+			// grows with it
+			if fanOut, ok := kialiGraph.FanOut(id, target); ok {
+				if inbound := kialiGraph.InboundRate(target); inbound > 0 {
+					growth := float64(replicaCounts[currentService])/float64(oldReplicaCounts[currentService]) - 1
+					extraRate := growth * kialiGraph.InboundRate(id) * fanOut
+					newQueueLength = queueLengths[serviceToScale] * (1 + extraRate/inbound)
+					log.Printf("[fan-out: %s -> %s] %.2f calls per request, extra rate: %.2f req/s\n", currentService, serviceToScale, fanOut, extraRate)
+				}
+			}
 
 			// // current formula :
 			// // 	newQ = oldQueue * parent_rc / service_rc
@@ -227,33 +425,45 @@ func (tc *Client) scaleDeployements(ctx context.Context, baseDeps map[string]Res
 			)
 			if newReplicaCount > replicaCounts[serviceToScale] {
 				replicaCounts[serviceToScale] = newReplicaCount
-				graphQueue.PushBack(serviceToScale)
+				raised[serviceToScale] = true
 			}
 		}
 	}
+}
 
-	for service, replicaCount := range replicaCounts {
-		if replicaCount > oldReplicaCounts[service] {
-			log.Printf(
-				"[replicas for: %s] old replica count: %d, new replica count: %d\n",
-				service,
-				oldReplicaCounts[service],
-				replicaCount,
-			)
-			tc.K8sClient.ScaleDeployment(ctx, applicationNamespace, service, int32(replicaCount))
-		}
+// nodeNames returns the names of the nodes with the given IDs
+func nodeNames(kialiGraph kiali.Graph, ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = kialiGraph[id].Name()
 	}
 
-	return nil
+	return names
 }
 
-func (tc *Client) checkThroughput(ctx context.Context, throughput int64) error {
-	currentThroughput, err := tc.GetE2EThroughput(ctx)
-	log.Println("E2E Throughput: ", currentThroughput)
-	if err != nil {
-		log.Println(err)
-		return err
+// checkThroughput checks the e2e throughput of the application in a snapshot
+// against the throughput to be maintained, logging it for every entry point.
+func (tc *Client) checkThroughput(s *snapshot, throughput int64) error {
+	entries := s.graph.SelectEntries(tc.thresholds.Entry)
+	if len(entries) == 0 {
+		log.Println(errNoEntryPoints)
+		return errNoEntryPoints
+	}
+	throughputs := s.graph.EntryThroughputs(entries)
+
+	// Sum up entry points in order, logging each
+	names := make([]string, 0, len(throughputs))
+	for entry := range throughputs {
+		names = append(names, entry)
 	}
+	sort.Strings(names)
+
+	currentThroughput := int64(0)
+	for _, entry := range names {
+		log.Printf("[e2e throughput: %s] %d\n", entry, throughputs[entry])
+		currentThroughput += throughputs[entry]
+	}
+	log.Println("E2E Throughput: ", currentThroughput)
 
 	if currentThroughput < throughput {
 		return errScaleApplication
@@ -263,112 +473,88 @@ func (tc *Client) checkThroughput(ctx context.Context, throughput int64) error {
 }
 
 func (tc *Client) GetE2EThroughput(ctx context.Context) (int64, error) {
-	namespaces := []string{applicationNamespace}
-	parameters := map[string]string{
-		"responseTime": "avg",
-		"throughput":   "response",
-		"duration":     "5m",
-	}
-
 	// Get workload graph for a namespace
-	graph, err := tc.KialiClient.GetWorkloadGraph(ctx, namespaces, parameters)
+	graph, err := tc.getGraph(ctx, 5*time.Minute)
 	if err != nil {
 		return -1, err
 	}
 
-	// Get Unkown ID
-	unknownID := ""
-	for service, item := range graph {
-		if item.Node.Workload == "unknown" {
-			unknownID = service
-			break
-		}
-	}
+	return tc.getE2EThroughput(graph)
+}
 
-	if unknownID == "" {
-		return -1, errors.New("no unkown service")
+// getE2EThroughput calculates the e2e throughput of an application from its
+// graph, as the sum of the throughput entering it through every entry point.
+func (tc *Client) getE2EThroughput(graph kiali.Graph) (int64, error) {
+	entries := graph.SelectEntries(tc.thresholds.Entry)
+	if len(entries) == 0 {
+		return -1, errNoEntryPoints
 	}
 
-	item := graph[unknownID]
 	currentThroughput := int64(0)
-
-	// Calculate e2e throughput as sum of throughput at unknowns
-	for _, edge := range item.Edges {
-		t, _ := strconv.ParseInt(edge.Throughput, 10, 64)
-		currentThroughput += t
+	for _, throughput := range graph.EntryThroughputs(entries) {
+		currentThroughput += throughput
 	}
 
 	return currentThroughput, nil
 }
 
-func (tc *Client) GetRequestRate(ctx context.Context) (float64, error) {
-	namespaces := []string{applicationNamespace}
-	parameters := map[string]string{
-		"responseTime": "avg",
-		"throughput":   "response",
-		"duration":     "5m",
+// GetE2EThroughputByEntry returns the e2e throughput of an application broken
+// down by entry point
+func (tc *Client) GetE2EThroughputByEntry(ctx context.Context) (map[string]int64, error) {
+	graph, err := tc.getGraph(ctx, 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := graph.SelectEntries(tc.thresholds.Entry)
+	if len(entries) == 0 {
+		return nil, errNoEntryPoints
 	}
 
+	return graph.EntryThroughputs(entries), nil
+}
+
+func (tc *Client) GetRequestRate(ctx context.Context) (float64, error) {
 	// Get workload graph for a namespace
-	graph, err := tc.KialiClient.GetWorkloadGraph(ctx, namespaces, parameters)
+	graph, err := tc.getGraph(ctx, 5*time.Minute)
 	if err != nil {
 		return -1, err
 	}
 
-	// Get Unkown ID
-	unknownID := ""
-	for service, item := range graph {
-		if item.Node.Workload == "unknown" {
-			unknownID = service
-			break
-		}
-	}
+	return tc.getRequestRate(graph)
+}
 
-	if unknownID == "" {
-		return -1, errors.New("no unkown service")
+// getRequestRate calculates the rate of requests coming into an application
+// from its graph, summed over every entry point.
+func (tc *Client) getRequestRate(graph kiali.Graph) (float64, error) {
+	entries := graph.SelectEntries(tc.thresholds.Entry)
+	if len(entries) == 0 {
+		return -1, errNoEntryPoints
 	}
 
-	item := graph[unknownID]
 	currentRequestRate := float64(0)
-
-	// Calculate e2e throughput as sum of throughput at unknowns
-	for _, edge := range item.Edges {
-		t, _ := strconv.ParseFloat(edge.Traffic.Rates["http"], 64)
-		currentRequestRate += t
+	for _, rate := range graph.EntryRequestRates(entries) {
+		currentRequestRate += rate
 	}
 
 	return currentRequestRate, nil
 }
 
-func (tc *Client) checkResources(ctx context.Context) error {
-	_, err := tc.getBaseDeployments(ctx)
+// checkResources checks the deployments in a snapshot for resource
+// utilization higher than their thresholds
+func (tc *Client) checkResources(s *snapshot) error {
+	_, err := tc.getBaseDeployments(s)
 	return err
 }
 
 // getBaseDeployments returns a slice of deployment names that have resource
-// utilization higher than specified threshold along with their respoective metrics
-func (tc *Client) getBaseDeployments(ctx context.Context) (map[string]Resources, error) {
+// utilization or custom metrics higher than specified threshold along with their
+// respoective metrics
+func (tc *Client) getBaseDeployments(s *snapshot) (map[string]Resources, error) {
 	baseDeps := make(map[string]Resources)
 
-	pods, err := tc.K8sClient.GetPodNames(ctx, applicationNamespace)
-	if err != nil {
-		return baseDeps, err
-	}
-
-	deps, err := tc.K8sClient.GetDeploymentNames(ctx, applicationNamespace)
-	if err != nil {
-		return baseDeps, err
-	}
-
-	// create a mapping of deployments -> pods belonging to that
-	// deployment.
-	depsToPods := make(map[string][]string)
-	for _, dep := range deps {
-		depsToPods[dep] = getPodsForDeployment(dep, pods)
-	}
-
-	// get current deployment metrics
-	depMetrics := tc.getPerDeploymentMetrics(ctx, depsToPods)
+	// current deployment metrics
+	depMetrics := s.depMetrics
 
 	metricsBs, _ := json.MarshalIndent(depMetrics, "", "  ")
 	thresholdBs, _ := json.MarshalIndent(tc.thresholds.ResourceThresholds, "", "  ")
@@ -391,6 +577,20 @@ func (tc *Client) getBaseDeployments(ctx context.Context) (map[string]Resources,
 		}
 	}
 
+	// Likewise for deployments with custom or external metrics above their
+	// thresholds
+	for dep, metricThresholds := range tc.thresholds.MetricThresholds {
+		metrics := depMetrics[dep]
+		for _, threshold := range metricThresholds {
+			value, ok := metrics.Custom[threshold.Metric]
+			if ok && value > threshold.Target && threshold.Target > 0 {
+				log.Printf("[metric: %s] %s: %.3f, above: %.3f\n", dep, threshold.Metric, value, threshold.Target)
+				baseDeps[dep] = metrics
+				break
+			}
+		}
+	}
+
 	if len(baseDeps) > 0 {
 		return baseDeps, errScaleApplication
 	}
@@ -398,74 +598,108 @@ func (tc *Client) getBaseDeployments(ctx context.Context) (map[string]Resources,
 	return baseDeps, nil
 }
 
-func (tc *Client) getPerDeploymentMetrics(ctx context.Context, depPodMap map[string][]string) map[string]Resources {
+// getDeploymentMetrics returns the current metrics of the deployments whose
+// pods are matched by the given selectors, keyed by deployment name. The
+// metrics of every pod they may select are listed in a single call and joined
+// to the deployments by their labels. When the sample of a deployment was taken
+// and over what window is logged, and samples older than staleMetricsAge are
+// flagged as stale.
+func (tc *Client) getDeploymentMetrics(ctx context.Context, depSelectors map[string]labels.Selector) map[string]Resources {
 	resourceMap := make(map[string]Resources)
-	for dep, pods := range depPodMap {
-		for _, pod := range pods {
-			metrics, err := tc.MetricClient.GetPodMetrics(ctx, applicationNamespace, pod)
-			if err != nil {
-				// print out error and not return to try and get as many pod metrics
-				// as possible.
-				// log.Printf("error %s getting metrics of pod %s", err.Error(), pod)
-			}
-			resourceMap[dep] = aggregatePodMetricsToResources(metrics)
+
+	pods, err := tc.MetricClient.ListPodMetrics(ctx, applicationNamespace, podSelector(depSelectors))
+	if err != nil {
+		log.Println("error getting pod metrics:", err)
+		return resourceMap
+	}
+
+	now := time.Now()
+	for dep, metrics := range metricscraper.AggregateDeploymentMetrics(pods, depSelectors) {
+		age := now.Sub(metrics.Timestamp).Round(time.Second)
+		stale := ""
+		if age > staleMetricsAge {
+			stale = ", stale"
 		}
+		log.Printf("[metrics: %s] %d pods, sampled %s ago over %s%s\n", dep, metrics.Pods, age, metrics.Window, stale)
+
+		resourceMap[dep] = Resources{CPU: metrics.CPU, Memory: metrics.Memory}
 	}
 
 	return resourceMap
 }
 
-// this is a super hacky way of doing this, but we shall make our peace with it for now.
-func getPodsForDeployment(deploymentName string, pods []string) []string {
-	resPods := []string{}
-
-	for _, pod := range pods {
-		if strings.HasPrefix(pod, deploymentName+"-") {
-			resPods = append(resPods, pod)
+// podSelector returns a selector for the pods of every deployment, narrowing
+// the pods whose metrics are listed down from the whole namespace. It can only
+// be told when every deployment selects its pods on the value of a label they
+// all share, e.g. "app", and matches every pod otherwise.
+func podSelector(depSelectors map[string]labels.Selector) labels.Selector {
+	var values map[string]sets.String
+	for _, selector := range depSelectors {
+		requirements, selectable := selector.Requirements()
+		if !selectable {
+			return labels.Everything()
 		}
-	}
 
-	return resPods
-}
+		equals := make(map[string]sets.String)
+		for _, r := range requirements {
+			if r.Operator() == selection.Equals || r.Operator() == selection.DoubleEquals || r.Operator() == selection.In {
+				equals[r.Key()] = r.Values()
+			}
+		}
 
-func aggregatePodMetricsToResources(metrics *v1beta1.PodMetrics) Resources {
-	r := Resources{}
-	numContainers := len(metrics.Containers)
-	if numContainers == 0 {
-		return r
+		if values == nil {
+			values = equals
+			continue
+		}
+		for key := range values {
+			if _, ok := equals[key]; !ok {
+				delete(values, key)
+				continue
+			}
+			values[key] = values[key].Union(equals[key])
+		}
 	}
 
-	var cpuSum, memSum float64
-	for _, c := range metrics.Containers {
-		cpuSum += c.Usage.Cpu().AsApproximateFloat64()
-		memSum += c.Usage.Memory().AsApproximateFloat64()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
 	}
+	if len(keys) == 0 {
+		return labels.Everything()
+	}
+	sort.Strings(keys)
 
-	r.CPU = cpuSum / float64(numContainers)
-	r.Memory = memSum / float64(numContainers)
+	r, err := labels.NewRequirement(keys[0], selection.In, values[keys[0]].List())
+	if err != nil {
+		return labels.Everything()
+	}
 
-	return r
+	return labels.NewSelector().Add(*r)
 }
 
 // getNewReplicaCounts gets replica counts for problematic deployments,
-// i. e., deployments where resource thresholds are exceeded.
+// i. e., deployments where resource thresholds are exceeded, from their
+// current replica counts in depReplicas.
 // This function returns a map of deployments to their new (HPA) replica counts
-func (tc *Client) getNewReplicaCounts(ctx context.Context, baseDeps map[string]Resources, namespace string) (map[string]int64, error) {
+func (tc *Client) getNewReplicaCounts(baseDeps map[string]Resources, depReplicas map[string]int) map[string]int64 {
 
 	// desiredReplicas := ceil[currentReplicas * ( currentMetricValue / desiredMetricValue )]
 
 	baseDepsNewReplicaCounts := make(map[string]int64)
 
 	for dep, currentMetrics := range baseDeps {
-		currentReplicas, err := tc.K8sClient.GetCurrentReplicaCount(ctx, namespace, dep)
-		if err != nil {
-			return baseDepsNewReplicaCounts, err
-		}
+		currentReplicas := depReplicas[dep]
 
 		desiredMetrics := tc.thresholds.ResourceThresholds[dep]
 
-		desiredReplicasCPU := int64(math.Ceil(float64(currentReplicas) * currentMetrics.CPU / desiredMetrics.CPU))
-		desiredReplicasMemory := int64(math.Ceil(float64(currentReplicas) * currentMetrics.Memory / currentMetrics.Memory))
+		desiredReplicasCPU := int64(0)
+		if desiredMetrics.CPU > 0 {
+			desiredReplicasCPU = int64(math.Ceil(float64(currentReplicas) * currentMetrics.CPU / desiredMetrics.CPU))
+		}
+		desiredReplicasMemory := int64(0)
+		if desiredMetrics.Memory > 0 {
+			desiredReplicasMemory = int64(math.Ceil(float64(currentReplicas) * currentMetrics.Memory / desiredMetrics.Memory))
+		}
 
 		desiredReplicas := int64(0)
 
@@ -475,8 +709,21 @@ func (tc *Client) getNewReplicaCounts(ctx context.Context, baseDeps map[string]R
 			desiredReplicas = desiredReplicasMemory
 		}
 
+		// Custom and external metrics ask for replicas by the same ratio
+		for _, threshold := range tc.thresholds.MetricThresholds[dep] {
+			value, ok := currentMetrics.Custom[threshold.Metric]
+			if !ok || threshold.Target <= 0 {
+				continue
+			}
+
+			desiredReplicasMetric := int64(math.Ceil(float64(currentReplicas) * value / threshold.Target))
+			if desiredReplicasMetric > desiredReplicas {
+				desiredReplicas = desiredReplicasMetric
+			}
+		}
+
 		baseDepsNewReplicaCounts[dep] = desiredReplicas
 	}
 
-	return baseDepsNewReplicaCounts, nil
+	return baseDepsNewReplicaCounts
 }
@@ -0,0 +1,60 @@
+package trigger
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestPodSelector(t *testing.T) {
+	cases := []struct {
+		name      string
+		selectors map[string]string
+		want      string
+	}{
+		{"no deployments", map[string]string{}, ""},
+		{"single deployment", map[string]string{"a": "app=a"}, "app in (a)"},
+		{"shared key", map[string]string{"a": "app=a", "b": "app==b"}, "app in (a,b)"},
+		{"no shared key", map[string]string{"a": "app=a", "b": "name=b"}, ""},
+		{"selecting every pod", map[string]string{"a": "app=a", "b": ""}, ""},
+		{"existence only", map[string]string{"a": "app", "b": "app"}, ""},
+		{"not equal left out", map[string]string{"a": "app!=a,tier=x", "b": "tier=y"}, "tier in (x,y)"},
+		{
+			"in unions narrow on the first key only",
+			map[string]string{"a": "app in (a,b),tier=x", "b": "app=c,tier in (y)"},
+			"app in (a,b,c)",
+		},
+		{
+			"keys not shared by all dropped",
+			map[string]string{"a": "app=a,tier=x", "b": "tier=y,version=v1", "c": "tier=z,version=v2"},
+			"tier in (x,y,z)",
+		},
+	}
+
+	for _, c := range cases {
+		depSelectors := make(map[string]labels.Selector)
+		for dep, s := range c.selectors {
+			selector, err := labels.Parse(s)
+			if err != nil {
+				t.Fatalf("%s: error parsing %q: %v", c.name, s, err)
+			}
+			depSelectors[dep] = selector
+		}
+
+		if got := podSelector(depSelectors).String(); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestPodSelectorNotSelectable(t *testing.T) {
+	// A selector matching nothing cannot be told apart from the others
+	depSelectors := map[string]labels.Selector{
+		"a": labels.SelectorFromSet(labels.Set{"app": "a"}),
+		"b": labels.Nothing(),
+	}
+
+	if got := podSelector(depSelectors); !got.Empty() {
+		t.Fatalf("expected a selector matching every pod, got %q", got.String())
+	}
+}
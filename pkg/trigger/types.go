@@ -1,6 +1,11 @@
 package trigger
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/Gituser143/stunning-octo-enigma/pkg/kiali"
+)
 
 // TODO
 // hardcoding for now, yeet later.
@@ -8,15 +13,171 @@ const applicationNamespace = "istio-teastore"
 
 var errScaleApplication = errors.New("scale application")
 
-// Resources holds CPU and Memory values as float64
+// Origins of scaling actions
+const (
+	originReactive    = "reactive"
+	originFeedForward = "feedforward"
+	originPredictive  = "predictive"
+	originPID         = "pid"
+)
+
+// staleMetricsAge is the age past which pod metrics are flagged as stale, well
+// over the scrape interval of the metrics server
+const staleMetricsAge = 2 * time.Minute
+
+var errNoPodBudget = errors.New("no pod budget configured or found in resource quota")
+
+var errNoEntryPoints = errors.New("no entry points in graph")
+
+// Resources holds CPU and Memory values as float64, along with the values of
+// custom and external metrics keyed by metric name
 type Resources struct {
-	CPU    float64 `json:"cpu"`
-	Memory float64 `json:"memory"`
+	CPU    float64            `json:"cpu"`
+	Memory float64            `json:"memory"`
+	Custom map[string]float64 `json:"custom,omitempty"`
+}
+
+// Types of metrics thresholds can be set on, see MetricThreshold
+const (
+	MetricTypePods     = "pods"
+	MetricTypeObject   = "object"
+	MetricTypeExternal = "external"
+)
+
+// MetricThreshold is a threshold on a metric served through the custom or
+// external metrics API, e.g. by a prometheus adapter. With Type "pods", the
+// default, Metric is averaged over the pods of the deployment, with "object"
+// it is read off the deployment itself and with "external" it is summed over
+// its series from outside the cluster. Selector narrows down the series of the
+// metric read. A deployment above Target is a base deployment, and is scaled
+// by the ratio of its value to Target as with CPU.
+type MetricThreshold struct {
+	Metric   string            `json:"metric"`
+	Type     string            `json:"type"`
+	Selector map[string]string `json:"selector"`
+	Target   float64           `json:"target"`
 }
 
 // Thresholds hold per deployment resource thresholds along with the e2e
-// throughput to be maintained for an application
+// throughput to be maintained for an application. Priorities rank deployments
+// when the cluster cannot fit every replica a scaling cycle asks for, higher
+// values are served first. GraphType picks the kiali graph scaling decisions
+// are made on, "workload" by default. On "app", "versionedApp" and "service"
+// graphs nodes are resolved to the deployments backing them, and queue length
+// thresholds and priorities are keyed by node name. Entry picks the nodes
+// through which traffic enters the application, see kiali.EntrySelector.
+// Source picks where the dependency graph is taken from, see SourceConfig.
+// MetricThresholds sets thresholds on custom and external metrics per
+// deployment, alongside the resource thresholds.
 type Thresholds struct {
-	ResourceThresholds map[string]Resources `json:"resourceThresholds"`
-	Throughput         int64                `json:"throughput"`
+	GraphType          string                       `json:"graphType"`
+	ResourceThresholds map[string]Resources         `json:"resourceThresholds"`
+	MetricThresholds   map[string][]MetricThreshold `json:"metricThresholds"`
+	Throughput         int64                        `json:"throughput"`
+	Priorities         map[string]int               `json:"priorities"`
+	Optimiser          OptimiserConfig              `json:"optimiser"`
+	FeedForward        FeedForwardConfig            `json:"feedForward"`
+	Predictive         PredictiveConfig             `json:"predictive"`
+	PID                PIDConfig                    `json:"pid"`
+	QueueLengths       QueueLengthConfig            `json:"queueLengths"`
+	Health             HealthConfig                 `json:"health"`
+	Entry              kiali.EntrySelector          `json:"entry"`
+	Source             SourceConfig                 `json:"source"`
+}
+
+// HealthConfig makes the health kiali computes for workloads an input to
+// scaling. Workloads failing more than ErrorRatio of their inbound requests
+// are scaled up by that ratio, as a signal alongside the resource thresholds.
+// Workloads with less than MinAvailability of their desired replicas
+// available are vetoed from scaling, as more replicas of pods which do not
+// become ready will not help. Either is off when 0. Error ratios are taken
+// over RateInterval seconds, kiali's default when 0.
+type HealthConfig struct {
+	Enabled         bool    `json:"enabled"`
+	ErrorRatio      float64 `json:"errorRatio"`
+	MinAvailability float64 `json:"minAvailability"`
+	RateInterval    int     `json:"rateInterval"`
+}
+
+// QueueLengthConfig picks where queue lengths are taken from. With Source
+// "graph", the default, they are read off a single snapshot of the graph. With
+// "metrics" they come from the kiali metrics of every workload, averaged over
+// the last Window seconds sampled every Step seconds, with rates taken over
+// RateInterval seconds (kiali's default when 0).
+type QueueLengthConfig struct {
+	Source       string `json:"source"`
+	Window       int    `json:"window"`
+	Step         int    `json:"step"`
+	RateInterval int    `json:"rateInterval"`
+}
+
+// OptimiserConfig switches scaling from giving every flagged service what its
+// own formula demands over to spreading a fixed budget of extra pods across
+// the dependency graph. A PodBudget of 0 takes the budget from the pods left
+// in the namespace ResourceQuota, and without a pods quota scaling falls back
+// to the formulas.
+type OptimiserConfig struct {
+	Enabled   bool `json:"enabled"`
+	PodBudget int  `json:"podBudget"`
+}
+
+// FeedForwardConfig enables scaling ahead of the load schedule handed to the
+// trigger. StartupLatency is the number of seconds a new pod takes to become
+// ready, and is how far ahead of a load step its replicas are asked for.
+type FeedForwardConfig struct {
+	Enabled        bool `json:"enabled"`
+	StartupLatency int  `json:"startupLatency"`
+}
+
+// PredictiveConfig enables scaling ahead of demand forecast from the history
+// of request rates. Every Interval seconds the request rates of the application
+// and its workloads are sampled and a Holt-Winters model with the given
+// smoothing factors projects them Horizon seconds ahead. SeasonLength is in
+// samples, with 0 meaning no seasonality, and Window is the number of samples
+// kept to judge forecast accuracy over.
+type PredictiveConfig struct {
+	Enabled      bool    `json:"enabled"`
+	Interval     int     `json:"interval"`
+	Horizon      int     `json:"horizon"`
+	SeasonLength int     `json:"seasonLength"`
+	Window       int     `json:"window"`
+	Alpha        float64 `json:"alpha"`
+	Beta         float64 `json:"beta"`
+	Gamma        float64 `json:"gamma"`
+}
+
+// PIDConfig switches the throughput check from a yes or no answer to closed
+// loop control, with Throughput as the setpoint. The relative error of the
+// e2e throughput is fed through a PID controller with gains Kp, Ki and Kd,
+// whose output is the relative capacity to add. The integral term is clamped
+// to IntegralLimit and the output to OutputLimit.
+type PIDConfig struct {
+	Enabled       bool    `json:"enabled"`
+	Kp            float64 `json:"kp"`
+	Ki            float64 `json:"ki"`
+	Kd            float64 `json:"kd"`
+	IntegralLimit float64 `json:"integralLimit"`
+	OutputLimit   float64 `json:"outputLimit"`
+}
+
+// LoadStep is a planned step of load on the application, sending Rate
+// requests per second for Duration from Start onwards. Index is the position
+// of the step in the whole schedule.
+type LoadStep struct {
+	Index    int
+	Start    time.Time
+	Rate     float64
+	Duration time.Duration
+}
+
+// ScalingEvent records a scaling action taken by the trigger. Origin tells
+// whether it was taken in reaction to a violation or ahead of a load step, in
+// which case StepStart holds the start of that step.
+type ScalingEvent struct {
+	Time        time.Time
+	Origin      string
+	Service     string
+	OldReplicas int
+	NewReplicas int
+	StepStart   time.Time
 }